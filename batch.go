@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// BatchModeEnv is key of BATCH_MODE
+	BatchModeEnv = "BATCH_MODE"
+	// BatchWindowEnv is key of BATCH_WINDOW
+	BatchWindowEnv = "BATCH_WINDOW"
+	// BatchFormatEnv is key of BATCH_FORMAT
+	BatchFormatEnv = "BATCH_FORMAT"
+	// BatchLogBytesEnv is key of BATCH_LOG_BYTES
+	BatchLogBytesEnv = "BATCH_LOG_BYTES"
+	// BatchAttachments renders batched events as one attachment each
+	BatchAttachments = "attachments"
+	// BatchTable renders batched events as a table in one attachment
+	BatchTable = "table"
+	// BatchSwarm groups deaths of tasks of the same swarm service
+	BatchSwarm = "swarm"
+	// DefaultBatchWindow is how long a batch waits for more events
+	DefaultBatchWindow = 10 * time.Second
+	// SwarmServiceIDLabel is label of the swarm service of a task container
+	SwarmServiceIDLabel = "com.docker.swarm.service.id"
+	// SwarmServiceNameLabel is label of the swarm service name of a task container
+	SwarmServiceNameLabel = "com.docker.swarm.service.name"
+)
+
+// shortTruncationMarker replaces logs when a share is too small for telling
+// how much was cut
+const shortTruncationMarker = "…\n"
+
+type batchItem struct {
+	e *Event
+	m *Message
+}
+
+// Batcher groups messages of events with the same key which arrive within
+// window into a single message
+type Batcher struct {
+	window   time.Duration
+	format   string
+	logBytes int
+	key      func(e *Event) string
+	send     func(e *Event, m *Message)
+
+	mu      sync.Mutex
+	pending map[string][]batchItem
+	timers  map[string]*time.Timer
+}
+
+// NewBatcher is constructor. Events for which key returns "" aren't batched.
+// format is BatchAttachments or BatchTable. logBytes limits the logs of all
+// events of a batch together, 0 means no limit.
+func NewBatcher(window time.Duration, format string, logBytes int, key func(e *Event) string, send func(e *Event, m *Message)) *Batcher {
+	return &Batcher{
+		window:   window,
+		format:   format,
+		logBytes: logBytes,
+		key:      key,
+		send:     send,
+		pending:  make(map[string][]batchItem),
+		timers:   make(map[string]*time.Timer),
+	}
+}
+
+// swarmServiceKey batches deaths of swarm tasks by their service
+func swarmServiceKey(e *Event) string {
+	if e.Type != Die {
+		return ""
+	}
+	return e.Labels[SwarmServiceIDLabel]
+}
+
+// Add queues m of e into its batch. It returns false when e isn't batched
+// and m should be sent right away.
+func (b *Batcher) Add(e *Event, m *Message) bool {
+	key := b.key(e)
+	if key == "" {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[key]; !ok {
+		b.timers[key] = time.AfterFunc(b.window, func() { b.flush(key) })
+	}
+	b.pending[key] = append(b.pending[key], batchItem{e: e, m: m})
+	return true
+}
+
+func (b *Batcher) flush(key string) {
+	b.mu.Lock()
+	items := b.pending[key]
+	delete(b.pending, key)
+	delete(b.timers, key)
+	b.mu.Unlock()
+
+	switch len(items) {
+	case 0:
+		return
+	case 1:
+		b.send(items[0].e, items[0].m)
+		return
+	}
+	m := mergeMessages(items, b.format)
+	if b.logBytes > 0 {
+		shareLogs(m.Attachments, b.logBytes)
+	}
+	// Route, limit and track the batch like its most severe event
+	b.send(worstItem(items).e, m)
+}
+
+// Close sends the pending batches right away
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	var keys []string
+	for key, timer := range b.timers {
+		if timer.Stop() {
+			keys = append(keys, key)
+		}
+	}
+	b.mu.Unlock()
+	for _, key := range keys {
+		b.flush(key)
+	}
+}
+
+// mergeMessages combines the attachments of items into one message, or
+// tabulates them into one attachment in BatchTable format
+func mergeMessages(items []batchItem, format string) *Message {
+	first := items[0].e
+	service := first.Labels[SwarmServiceNameLabel]
+	if service == "" {
+		service = first.Labels[SwarmServiceIDLabel]
+	}
+	m := &Message{
+		Text: fmt.Sprintf("%d tasks of service %s died", len(items), service),
+	}
+	for _, item := range items {
+		m.events = append(m.events, item.e)
+	}
+	if format == BatchTable {
+		m.Attachments = []Attachment{tabulate(items)}
+		return m
+	}
+	for _, item := range items {
+		m.Attachments = append(m.Attachments, item.m.Attachments...)
+	}
+	return m
+}
+
+// worstItem returns the first of the most severe items
+func worstItem(items []batchItem) batchItem {
+	worst := items[0]
+	for _, item := range items {
+		if item.e.Severity > worst.e.Severity {
+			worst = item
+		}
+	}
+	return worst
+}
+
+// tabulate renders container, exit code and time of items as a table in
+// one attachment colored like the most severe item
+func tabulate(items []batchItem) Attachment {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tEXIT CODE\tTIME")
+	for _, item := range items {
+		e := item.e
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, e.ExitCode, e.Time.Format("15:04:05"))
+	}
+	w.Flush()
+	worst := worstItem(items)
+	a := Attachment{
+		Text: "```" + buf.String() + "```",
+		TS:   worst.e.Time.Unix(),
+	}
+	if len(worst.m.Attachments) > 0 {
+		a.Color = worst.m.Attachments[0].Color
+	}
+	return a
+}
+
+// shareLogs truncates the logs of attachments so that they sum up to at most
+// budget bytes. Logs shorter than an even share are kept whole and leave the
+// rest to the longer ones, which keep the same share of their end each.
+func shareLogs(attachments []Attachment, budget int) {
+	var withLogs []int
+	for i := range attachments {
+		if attachments[i].logs != "" {
+			withLogs = append(withLogs, i)
+		}
+	}
+	sort.SliceStable(withLogs, func(i, j int) bool {
+		return len(attachments[withLogs[i]].logs) < len(attachments[withLogs[j]].logs)
+	})
+	for n, i := range withLogs {
+		a := &attachments[i]
+		share := budget / (len(withLogs) - n)
+		if len(a.logs) > share {
+			a.logs = truncateLogs(a.logs, share)
+			a.Text = ""
+			if a.logs != "" {
+				a.Text = "```" + a.logs + "```"
+			}
+		}
+		budget -= len(a.logs)
+		if budget < 0 {
+			budget = 0
+		}
+	}
+}
+
+// truncateLogs keeps the end of logs which fits into limit bytes together
+// with a marker of how much was cut, starting at a whole line when there is
+// one. When not even the marker fits, only "…" is left, or nothing.
+func truncateLogs(logs string, limit int) string {
+	if len(logs) <= limit {
+		return logs
+	}
+	marker := func(cut int) string {
+		return fmt.Sprintf("…(%d bytes truncated to fit the batch)\n", cut)
+	}
+	// The marker is longest when all of logs is cut
+	keep := limit - len(marker(len(logs)))
+	if keep < 0 {
+		if limit < len(shortTruncationMarker) {
+			return ""
+		}
+		return shortTruncationMarker
+	}
+	i := len(logs) - keep
+	// Cutting less may take fewer digits
+	if more := limit - len(marker(i)); more > keep {
+		i = len(logs) - more
+	}
+	if j := strings.IndexByte(logs[i:], '\n'); j >= 0 && logs[i-1] != '\n' {
+		i += j + 1
+	}
+	for i < len(logs) && !utf8.RuneStart(logs[i]) {
+		i++
+	}
+	return marker(i) + logs[i:]
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateLogs(t *testing.T) {
+	lines := strings.Repeat("0123456789\n", 20)
+	tests := []struct {
+		name  string
+		logs  string
+		limit int
+		want  string
+	}{
+		{name: "fits", logs: "short\n", limit: 100, want: "short\n"},
+		{name: "whole lines", logs: lines, limit: 80, want: "…(187 bytes truncated to fit the batch)\n0123456789\n0123456789\n0123456789\n"},
+		{name: "one long line", logs: strings.Repeat("x", 100), limit: 50, want: "…(91 bytes truncated to fit the batch)\n" + strings.Repeat("x", 9)},
+		{name: "rune boundary", logs: strings.Repeat("é", 50), limit: 50, want: "…(92 bytes truncated to fit the batch)\n" + strings.Repeat("é", 4)},
+		{name: "no room for the marker", logs: lines, limit: 20, want: shortTruncationMarker},
+		{name: "no room at all", logs: lines, limit: 2, want: ""},
+		{name: "zero", logs: lines, limit: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateLogs(tt.logs, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncateLogs() = %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.limit {
+				t.Errorf("len = %d, over limit %d", len(got), tt.limit)
+			}
+		})
+	}
+}
+
+func TestShareLogs(t *testing.T) {
+	long := strings.Repeat("some line of logs\n", 100)
+	tests := []struct {
+		name   string
+		logs   []string
+		budget int
+		kept   []bool
+	}{
+		{name: "all fit", logs: []string{"a\n", "b\n"}, budget: 10, kept: []bool{true, true}},
+		{name: "short one keeps its logs", logs: []string{long, "short\n", long}, budget: 600, kept: []bool{false, true, false}},
+		{name: "all cut", logs: []string{long, long, long}, budget: 300, kept: []bool{false, false, false}},
+		{name: "tiny budget", logs: []string{long, long, long, long}, budget: 10, kept: []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments := []Attachment{{Title: "no logs", Text: "text"}}
+			for _, logs := range tt.logs {
+				attachments = append(attachments, Attachment{logs: logs, Text: "```" + logs + "```"})
+			}
+			shareLogs(attachments, tt.budget)
+			if attachments[0].Text != "text" {
+				t.Errorf("attachment without logs changed to %q", attachments[0].Text)
+			}
+			total := 0
+			for i, a := range attachments[1:] {
+				total += len(a.logs)
+				if kept := a.logs == tt.logs[i]; kept != tt.kept[i] {
+					t.Errorf("logs %d kept = %v, want %v", i, kept, tt.kept[i])
+				}
+				if a.logs != "" && a.Text != "```"+a.logs+"```" {
+					t.Errorf("text %d = %q doesn't match its logs", i, a.Text)
+				}
+			}
+			if total > tt.budget {
+				t.Errorf("total = %d, over budget %d", total, tt.budget)
+			}
+		})
+	}
+}
+
+func TestShareLogsEvenly(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	attachments := []Attachment{{logs: long}, {logs: long}}
+	shareLogs(attachments, 400)
+	if len(attachments[0].logs) != len(attachments[1].logs) {
+		t.Errorf("shares = %d and %d, want equal", len(attachments[0].logs), len(attachments[1].logs))
+	}
+	if len(attachments[0].logs) != 200 {
+		t.Errorf("share = %d, want 200", len(attachments[0].logs))
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// ExitHistorySizeEnv is key of EXIT_HISTORY_SIZE
+	ExitHistorySizeEnv = "EXIT_HISTORY_SIZE"
+	// ExitHistoryField is title of the field of recent exit codes
+	ExitHistoryField = "exits"
+	// RestartPolicyField is title of the restart policy field
+	RestartPolicyField = "restart policy"
+	// DefaultCacheSize is maximum number of containers in ContainerCache
+	DefaultCacheSize = 1000
+)
+
+// containerMeta is what is remembered about a container between its events
+type containerMeta struct {
+	seen          time.Time
+	exits         []string
+	restartPolicy *container.RestartPolicy
+
+	health         string
+	healthSince    time.Time
+	healthReported bool
+
+	trigger   string
+	triggerAt time.Time
+	exec      string
+	execAt    time.Time
+	// unhealthy is the last health status of the container ID itself, unlike
+	// health which is kept by DEDUP_KEY for HEALTH_EVENTS
+	unhealthy bool
+
+	created time.Time
+}
+
+// ContainerCache keeps metadata per container ID. Containers are forgotten
+// when they are destroyed, or the least recently seen one when the cache is
+// full.
+type ContainerCache struct {
+	size int
+
+	mu         sync.Mutex
+	containers map[string]*containerMeta
+}
+
+// NewContainerCache is constructor
+func NewContainerCache(size int) *ContainerCache {
+	return &ContainerCache{
+		size:       size,
+		containers: make(map[string]*containerMeta),
+	}
+}
+
+// get returns metadata of id, adding it when missing. It must be called
+// with mu held.
+func (c *ContainerCache) get(id string) *containerMeta {
+	meta, ok := c.containers[id]
+	if !ok {
+		if len(c.containers) >= c.size {
+			c.evict()
+		}
+		meta = &containerMeta{}
+		c.containers[id] = meta
+	}
+	meta.seen = time.Now()
+	return meta
+}
+
+// evict forgets the least recently seen container. It must be called with
+// mu held.
+func (c *ContainerCache) evict() {
+	var oldest string
+	for id, meta := range c.containers {
+		if oldest == "" || meta.seen.Before(c.containers[oldest].seen) {
+			oldest = id
+		}
+	}
+	delete(c.containers, oldest)
+}
+
+// Forget drops metadata of id
+func (c *ContainerCache) Forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.containers, id)
+}
+
+// RecordExit appends code to the exit history of id, keeping the last max
+// codes, and returns the history oldest first
+func (c *ContainerCache) RecordExit(id, code string, max int) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.get(id)
+	meta.exits = append(meta.exits, code)
+	if len(meta.exits) > max {
+		meta.exits = append(meta.exits[:0], meta.exits[len(meta.exits)-max:]...)
+	}
+	exits := make([]string, len(meta.exits))
+	copy(exits, meta.exits)
+	return exits
+}
+
+// RestartPolicy returns the restart policy of id, inspecting the container
+// on the first call
+func (c *ContainerCache) RestartPolicy(ctx context.Context, cli *client.Client, id string) (*container.RestartPolicy, error) {
+	c.mu.Lock()
+	if meta, ok := c.containers[id]; ok && meta.restartPolicy != nil {
+		c.mu.Unlock()
+		return meta.restartPolicy, nil
+	}
+	c.mu.Unlock()
+
+	info, err := cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if info.HostConfig == nil {
+		return nil, fmt.Errorf("no host config of %s", id)
+	}
+	policy := info.HostConfig.RestartPolicy
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(id).restartPolicy = &policy
+	return &policy, nil
+}
+
+// formatRestartPolicy formats p like "on-failure (max 5)"
+func formatRestartPolicy(p *container.RestartPolicy) string {
+	name := p.Name
+	if name == "" {
+		name = "no"
+	}
+	if p.IsOnFailure() && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s (max %d)", name, p.MaximumRetryCount)
+	}
+	return name
+}
+
+// SetTrigger records what is going to make id die
+func (c *ContainerCache) SetTrigger(id, trigger string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.get(id)
+	meta.trigger = trigger
+	meta.triggerAt = time.Now()
+}
+
+// Trigger returns and clears what was recorded to make id die within window
+func (c *ContainerCache) Trigger(id string, window time.Duration) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	if !ok || time.Since(meta.triggerAt) > window {
+		return ""
+	}
+	trigger := meta.trigger
+	meta.trigger = ""
+	return trigger
+}
+
+// SetExec records the command of an exec started in id
+func (c *ContainerCache) SetExec(id, cmd string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.get(id)
+	meta.exec = cmd
+	meta.execAt = time.Now()
+}
+
+// Exec returns and clears the command of the last exec started in id within
+// window
+func (c *ContainerCache) Exec(id string, window time.Duration) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	if !ok || time.Since(meta.execAt) > window {
+		return ""
+	}
+	cmd := meta.exec
+	meta.exec = ""
+	return cmd
+}
+
+// SetUnhealthy records whether id is unhealthy
+func (c *ContainerCache) SetUnhealthy(id string, unhealthy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(id).unhealthy = unhealthy
+}
+
+// Unhealthy reports whether id was unhealthy at its last health status
+func (c *ContainerCache) Unhealthy(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	return ok && meta.unhealthy
+}
+
+// SetCreated records when id was created
+func (c *ContainerCache) SetCreated(id string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(id).created = t
+}
+
+// Created returns and clears when id was created, so only its first start
+// is timed. ok is false when the create wasn't observed.
+func (c *ContainerCache) Created(id string) (t time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	if !ok || meta.created.IsZero() {
+		return time.Time{}, false
+	}
+	t, meta.created = meta.created, time.Time{}
+	return t, true
+}
+
+// ContainerSnapshot is what is remembered about a container
+type ContainerSnapshot struct {
+	Seen           time.Time                `json:"seen"`
+	Exits          []string                 `json:"exits,omitempty"`
+	RestartPolicy  *container.RestartPolicy `json:"restart_policy,omitempty"`
+	Health         string                   `json:"health,omitempty"`
+	HealthReported bool                     `json:"health_reported,omitempty"`
+}
+
+// Snapshot returns what is remembered about each container
+func (c *ContainerCache) Snapshot() map[string]ContainerSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[string]ContainerSnapshot, len(c.containers))
+	for id, meta := range c.containers {
+		snapshot[id] = ContainerSnapshot{
+			Seen:           meta.seen,
+			Exits:          append([]string(nil), meta.exits...),
+			RestartPolicy:  meta.restartPolicy,
+			Health:         meta.health,
+			HealthReported: meta.healthReported,
+		}
+	}
+	return snapshot
+}
+
+// addRestartPolicy shows whether the container will be restarted. The field
+// is left out when the container can't be inspected.
+func (c *Config) addRestartPolicy(ctx context.Context, cli *client.Client, m *Message, e *Event) {
+	policy, err := c.Cache.RestartPolicy(ctx, cli, e.ID)
+	if err != nil {
+		log.Printf("restart policy of %s: %v", e.Name, err)
+		return
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: RestartPolicyField,
+		Value: formatRestartPolicy(policy),
+		Short: true,
+	})
+}
+
+// SetHealth records status of id. It returns false when status didn't
+// change, and the time since the container has had status.
+func (c *ContainerCache) SetHealth(id, status string) (bool, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.get(id)
+	if meta.health == status {
+		return false, meta.healthSince
+	}
+	meta.health = status
+	meta.healthSince = time.Now()
+	return true, meta.healthSince
+}
+
+// ReportUnhealthy marks id as reported unhealthy if it has been unhealthy
+// since since. It returns false when the container recovered meanwhile.
+func (c *ContainerCache) ReportUnhealthy(id string, since time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	if !ok || meta.health != Unhealthy || !meta.healthSince.Equal(since) {
+		return false
+	}
+	meta.healthReported = true
+	return true
+}
+
+// Recovered clears the reported unhealthy mark of id and returns whether it
+// was set
+func (c *ContainerCache) Recovered(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.containers[id]
+	if !ok {
+		return false
+	}
+	reported := meta.healthReported
+	meta.healthReported = false
+	return reported
+}
+
+// addExitHistory records the exit code of a die event and shows the history
+func (c *Config) addExitHistory(m *Message, e *Event) {
+	if e.Type != Die || c.ExitHistorySize <= 0 {
+		return
+	}
+	exits := c.Cache.RecordExit(c.DedupKey.Key(e), e.ExitCode, c.ExitHistorySize)
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: ExitHistoryField,
+		Value: strings.Join(exits, ", "),
+		Short: true,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// CallbackURLEnv is key of CALLBACK_URL
+	CallbackURLEnv = "CALLBACK_URL"
+	// CallbackActionsEnv is key of CALLBACK_ACTIONS
+	CallbackActionsEnv = "CALLBACK_ACTIONS"
+	// ActionNone does nothing
+	ActionNone = "none"
+	// ActionRestart restarts the container
+	ActionRestart = "restart"
+	// ActionStop stops the container
+	ActionStop = "stop"
+	// callbackActionTimeout bounds how long an action may take
+	callbackActionTimeout = time.Minute
+)
+
+// callbackRequest is body posted to the callback
+type callbackRequest struct {
+	Event   *Event   `json:"event"`
+	Message *Message `json:"message"`
+}
+
+// CallbackResponse is body the callback may respond with to act on the
+// container of the event, e.g. {"action":"restart"}
+type CallbackResponse struct {
+	Action string `json:"action"`
+	// Timeout is seconds to wait before killing the container on restart
+	// or stop
+	Timeout *int `json:"timeout,omitempty"`
+}
+
+// CallbackTarget posts events to a generic HTTP endpoint and performs the
+// action it responds with. Only actions listed in allowed are performed.
+type CallbackTarget struct {
+	url     *URLTemplate
+	cli     *client.Client
+	allowed []string
+}
+
+// NewCallbackTarget is constructor
+func NewCallbackTarget(url *URLTemplate, cli *client.Client, allowed []string) (*CallbackTarget, error) {
+	for _, a := range allowed {
+		if a != ActionRestart && a != ActionStop {
+			return nil, fmt.Errorf("unknown action %q in %s", a, CallbackActionsEnv)
+		}
+	}
+	return &CallbackTarget{
+		url:     url,
+		cli:     cli,
+		allowed: allowed,
+	}, nil
+}
+
+// Name returns name of target
+func (t *CallbackTarget) Name() string {
+	return "callback"
+}
+
+// Send posts e and m and acts on the response
+func (t *CallbackTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	u, err := t.url.Render(e)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&callbackRequest{Event: e, Message: m})
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, u, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var action CallbackResponse
+	if err := json.Unmarshal(body, &action); err != nil {
+		return fmt.Errorf("invalid callback response: %v", err)
+	}
+	ids := []string{e.ID}
+	if len(m.events) > 0 {
+		ids = ids[:0]
+		for _, e := range m.events {
+			ids = append(ids, e.ID)
+		}
+	}
+	for _, id := range ids {
+		if err := t.act(&action, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *CallbackTarget) act(action *CallbackResponse, id string) error {
+	if action.Action == "" || action.Action == ActionNone || id == "" {
+		return nil
+	}
+	if !t.isAllowed(action.Action) {
+		return fmt.Errorf("action %q is not allowed by %s", action.Action, CallbackActionsEnv)
+	}
+	var timeout *time.Duration
+	if action.Timeout != nil {
+		d := time.Duration(*action.Timeout) * time.Second
+		timeout = &d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callbackActionTimeout)
+	defer cancel()
+	log.Printf("callback: %s %s", action.Action, id)
+	switch action.Action {
+	case ActionRestart:
+		return t.cli.ContainerRestart(ctx, id, timeout)
+	case ActionStop:
+		return t.cli.ContainerStop(ctx, id, timeout)
+	}
+	return fmt.Errorf("unknown action %q", action.Action)
+}
+
+func (t *CallbackTarget) isAllowed(action string) bool {
+	for _, a := range t.allowed {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
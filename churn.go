@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ImageChurnEnv is key of IMAGE_CHURN, e.g. IMAGE_CHURN=3/1h
+	ImageChurnEnv = "IMAGE_CHURN"
+	// ImageChurnField is title of the field of image churn
+	ImageChurnField = "image churn"
+	// ComposeProjectLabel is label of the compose project of a container
+	ComposeProjectLabel = "com.docker.compose.project"
+	// ComposeServiceLabel is label of the compose service of a container
+	ComposeServiceLabel = "com.docker.compose.service"
+)
+
+type imageChange struct {
+	time  time.Time
+	image string
+}
+
+type churnHistory struct {
+	image   string
+	seen    time.Time
+	changes []imageChange
+}
+
+// ChurnDetector tracks images started per service and reports services
+// whose image changes more than max times within window
+type ChurnDetector struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	services map[string]*churnHistory
+}
+
+// NewChurnDetector is constructor
+func NewChurnDetector(max int, window time.Duration) *ChurnDetector {
+	return &ChurnDetector{
+		max:      max,
+		window:   window,
+		services: make(map[string]*churnHistory),
+	}
+}
+
+// churnKey is DEDUP_KEY of e, or by default the swarm or compose service of
+// e, or its container name
+func (c *Config) churnKey(e *Event) string {
+	if c.DedupKey != nil {
+		return c.DedupKey.Key(e)
+	}
+	if name := e.Labels[SwarmServiceNameLabel]; name != "" {
+		return name
+	}
+	if service := e.Labels[ComposeServiceLabel]; service != "" {
+		return e.Labels[ComposeProjectLabel] + "/" + service
+	}
+	return e.Name
+}
+
+// Observe records the image of start event e of service key. It returns the
+// images the service changed to within window when they are more than max.
+// Observe is nil-safe.
+func (d *ChurnDetector) Observe(key string, e *Event) []string {
+	if d == nil || e.Type != Start {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for k, h := range d.services {
+		if k != key && now.Sub(h.seen) > d.window {
+			delete(d.services, k)
+		}
+	}
+	h, ok := d.services[key]
+	if !ok {
+		d.services[key] = &churnHistory{image: e.Image, seen: now}
+		return nil
+	}
+	h.seen = now
+	if h.image != e.Image {
+		h.image = e.Image
+		h.changes = append(h.changes, imageChange{time: now, image: e.Image})
+	}
+	i := 0
+	for i < len(h.changes) && now.Sub(h.changes[i].time) > d.window {
+		i++
+	}
+	h.changes = h.changes[i:]
+	if len(h.changes) <= d.max {
+		return nil
+	}
+	images := make([]string, len(h.changes))
+	for i, c := range h.changes {
+		images[i] = c.image
+	}
+	return images
+}
+
+// inherit takes over the images services of old started
+func (d *ChurnDetector) inherit(old *ChurnDetector) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, h := range old.services {
+		copied := *h
+		copied.changes = append([]imageChange(nil), h.changes...)
+		d.services[key] = &copied
+	}
+}
+
+// addImageChurn tells that the service of e changes its image too often
+func (c *Config) addImageChurn(m *Message, e *Event, images []string) {
+	if len(images) == 0 {
+		return
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: ImageChurnField,
+		Value: fmt.Sprintf("%s changed its image %d times in %s: %s",
+			c.churnKey(e), len(images), c.Churn.window, strings.Join(images, ", ")),
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+const (
+	// ColorMapEnv is key of COLOR_MAP
+	ColorMapEnv = "COLOR_MAP"
+)
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// parseColorMap parses a JSON object mapping severities or event types to
+// "#rrggbb" colors. Invalid entries are logged and left out, so they fall
+// back to the default colors.
+func parseColorMap(s string) (map[string]string, error) {
+	colors := make(map[string]string)
+	if s == "" {
+		return colors, nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ColorMapEnv, err)
+	}
+	for key, v := range raw {
+		var color string
+		if err := json.Unmarshal(v, &color); err != nil || !hexColor.MatchString(color) {
+			log.Printf("%s: rejected color %s for %q, want \"#rrggbb\"", ColorMapEnv, v, key)
+			continue
+		}
+		if _, err := ParseSeverity(key); err != nil && !isEventType(key) {
+			log.Printf("%s: rejected unknown severity or event type %q", ColorMapEnv, key)
+			continue
+		}
+		colors[key] = color
+	}
+	return colors, nil
+}
+
+func isEventType(s string) bool {
+	for _, t := range EventTypes {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// colorize sets the color of m from COLOR_MAP. A color of the event type of
+// e takes precedence over a color of its severity, like in ROUTES.
+func (c *Config) colorize(m *Message, e *Event) {
+	color, ok := c.ColorMap[e.Type]
+	if !ok {
+		color, ok = c.ColorMap[e.Severity.String()]
+	}
+	if !ok {
+		return
+	}
+	for i := range m.Attachments {
+		m.Attachments[i].Color = color
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColorMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "unset", in: "", want: map[string]string{}},
+		{
+			name: "severities and event types",
+			in:   `{"info":"#2196f3","critical":"#B71C1C","start":"#9ccc65"}`,
+			want: map[string]string{"info": "#2196f3", "critical": "#B71C1C", "start": "#9ccc65"},
+		},
+		{
+			name: "invalid entries left out",
+			in:   `{"info":"blue","die":"#fff","oom":1,"nope":"#000000","warning":"#ffa000"}`,
+			want: map[string]string{"warning": "#ffa000"},
+		},
+		{name: "not an object", in: `["#ffffff"]`, wantErr: true},
+		{name: "not JSON", in: `info=#ffffff`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseColorMap(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseColorMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseColorMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorize(t *testing.T) {
+	config := &Config{ColorMap: map[string]string{"critical": "#b71c1c", "die": "#000000"}}
+	tests := []struct {
+		name string
+		e    *Event
+		want string
+	}{
+		{name: "event type wins", e: &Event{Type: Die, Severity: Critical}, want: "#000000"},
+		{name: "severity", e: &Event{Type: OOM, Severity: Critical}, want: "#b71c1c"},
+		{name: "default", e: &Event{Type: Start, Severity: Info}, want: StartColor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{Attachments: []Attachment{{Color: StartColor}, {Color: StartColor}}}
+			config.colorize(m, tt.e)
+			for _, a := range m.Attachments {
+				if a.Color != tt.want {
+					t.Errorf("color = %q, want %q", a.Color, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ErrorCounter counts errors by where they happened
+type ErrorCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewErrorCounter is constructor
+func NewErrorCounter() *ErrorCounter {
+	return &ErrorCounter{counts: make(map[string]int)}
+}
+
+// Inc counts an error of source
+func (c *ErrorCounter) Inc(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[source]++
+}
+
+// Counts returns number of errors of each source
+func (c *ErrorCounter) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.counts))
+	for source, n := range c.counts {
+		counts[source] = n
+	}
+	return counts
+}
+
+// targetState is state of a target in /debug/state
+type targetState struct {
+	Name      string          `json:"name"`
+	Enabled   bool            `json:"enabled"`
+	RateLimit *RateLimitState `json:"rate_limit,omitempty"`
+	Latency   *LatencyState   `json:"latency,omitempty"`
+}
+
+// debugState is body of /debug/state
+type debugState struct {
+	Config          Config                       `json:"config"`
+	LogErrorPattern string                       `json:"log_error_pattern,omitempty"`
+	Targets         []targetState                `json:"targets"`
+	Containers      map[string]ContainerSnapshot `json:"containers"`
+	Errors          map[string]int               `json:"errors"`
+}
+
+// redactURL keeps scheme and host of u, which are enough to tell targets
+// apart, and hides the rest which usually carries a token
+func redactURL(u string) string {
+	if u == "" {
+		return ""
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return "redacted"
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/redacted"
+}
+
+func (c *Config) debugState() *debugState {
+	config := *c
+	config.SlackURL = redactURL(c.SlackURL)
+	config.DiscordURL = redactURL(c.DiscordURL)
+	state := &debugState{
+		Config:     config,
+		Containers: c.Cache.Snapshot(),
+		Errors:     c.Errors.Counts(),
+	}
+	if c.LogErrorPattern != nil {
+		state.LogErrorPattern = c.LogErrorPattern.String()
+	}
+	for _, t := range c.Targets {
+		ts := targetState{
+			Name:    t.Name(),
+			Enabled: c.Switches.Enabled(t.Name()),
+		}
+		if limited, ok := t.(*RateLimitedTarget); ok {
+			rl := limited.State()
+			ts.RateLimit = &rl
+		}
+		if measured := latencyTarget(t); measured != nil {
+			ls := measured.State()
+			ts.Latency = &ls
+		}
+		state.Targets = append(state.Targets, ts)
+	}
+	return state
+}
+
+// handleDebugState dumps config with secrets redacted, filters, targets,
+// cached containers and error counts
+func (s *Server) handleDebugState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.holder.Load().debugState())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+const (
+	// DedupKeyEnv is key of DEDUP_KEY, e.g. DEDUP_KEY={{.Labels.service}}
+	DedupKeyEnv = "DEDUP_KEY"
+)
+
+// KeyTemplate groups events for deduplication, health debouncing, exit
+// history and image churn by a template rendered with the Event. A nil
+// KeyTemplate groups by container ID.
+type KeyTemplate struct {
+	raw  string
+	tmpl *template.Template
+}
+
+// NewKeyTemplate is constructor. It returns nil when raw is empty.
+func NewKeyTemplate(raw string) (*KeyTemplate, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("key").Option("missingkey=zero").Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", DedupKeyEnv, err)
+	}
+	return &KeyTemplate{raw: raw, tmpl: tmpl}, nil
+}
+
+// Key returns the group of e. Events whose key renders empty, e.g. without
+// the label, are grouped by their container ID.
+func (k *KeyTemplate) Key(e *Event) string {
+	if k == nil {
+		return e.ID
+	}
+	var b bytes.Buffer
+	if err := k.tmpl.Execute(&b, e); err != nil || b.Len() == 0 {
+		return e.ID
+	}
+	return b.String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (k *KeyTemplate) MarshalText() ([]byte, error) {
+	return []byte(k.raw), nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DiscordOverflowEnv is key of DISCORD_OVERFLOW
+	DiscordOverflowEnv = "DISCORD_OVERFLOW"
+	// DiscordMaxPostsEnv is key of DISCORD_MAX_POSTS
+	DiscordMaxPostsEnv = "DISCORD_MAX_POSTS"
+	// DiscordSplit posts logs which don't fit into the embed as follow-up messages
+	DiscordSplit = "split"
+	// DiscordTruncate keeps the tail of logs which fits into the embed
+	DiscordTruncate = "truncate"
+	// DiscordColorEnvFormat is format of DISCORD_<EVENT>_COLOR keys, e.g. DISCORD_START_COLOR
+	DiscordColorEnvFormat = "DISCORD_%s_COLOR"
+	// DefaultDiscordMaxPosts is maximum number of follow-up posts per message
+	DefaultDiscordMaxPosts = 5
+
+	discordContentLimit     = 2000
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordEmbedLimit       = 10
+	discordFieldLimit       = 25
+	discordFieldNameLimit   = 256
+	discordFieldValueLimit  = 1024
+	discordFooterLimit      = 2048
+	discordTotalLimit       = 6000
+	discordEmpty            = "\u200b"
+	codeFence               = "```"
+)
+
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// DiscordTarget posts messages to a Discord webhook as embeds
+type DiscordTarget struct {
+	url      *URLTemplate
+	overflow string
+	maxPosts int
+	colors   map[string]int
+	lang     string
+}
+
+// NewDiscordTarget is constructor. lang is the language hint of log code
+// blocks.
+func NewDiscordTarget(url *URLTemplate, overflow string, maxPosts int, colors map[string]int, lang string) *DiscordTarget {
+	return &DiscordTarget{
+		url:      url,
+		overflow: overflow,
+		maxPosts: maxPosts,
+		colors:   colors,
+		lang:     lang,
+	}
+}
+
+// Name returns name of target
+func (t *DiscordTarget) Name() string {
+	return "discord"
+}
+
+// Send posts m to Discord. Logs which don't fit into the embed are split
+// into follow-up posts or truncated depending on overflow.
+func (t *DiscordTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	u, err := t.url.Render(e)
+	if err != nil {
+		return err
+	}
+	dm, followUps := t.translate(e, m)
+	if err := t.post(ctx, u, dm); err != nil {
+		return err
+	}
+	for _, f := range followUps {
+		if err := t.post(ctx, u, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translate turns m into a post of embeds and its follow-ups. Embeds which
+// don't fit into the limits of a post, on their number and on the total of
+// their text, are carried over into follow-up posts.
+func (t *DiscordTarget) translate(e *Event, m *Message) (*discordMessage, []*discordMessage) {
+	dm := &discordMessage{
+		Content: truncate(m.Text, discordContentLimit),
+	}
+	var followUps []*discordMessage
+	post, total, chunked := dm, 0, false
+	for _, a := range m.Attachments {
+		embed, chunks := t.embed(e, a)
+		size := embedLen(embed)
+		// A post after the log chunks of an embed keeps them in order
+		if chunked || len(post.Embeds) == discordEmbedLimit || len(post.Embeds) > 0 && total+size > discordTotalLimit {
+			if len(followUps) == t.maxPosts {
+				break
+			}
+			post, total = &discordMessage{}, 0
+			followUps = append(followUps, post)
+		}
+		post.Embeds = append(post.Embeds, embed)
+		total += size
+		chunked = false
+		for _, chunk := range chunks {
+			if len(followUps) == t.maxPosts {
+				break
+			}
+			followUps = append(followUps, &discordMessage{Content: chunk})
+			chunked = true
+		}
+	}
+	return dm, followUps
+}
+
+// embed turns a into an embed within discordTotalLimit, and follow-up
+// messages of the logs which don't fit into it in DiscordSplit overflow.
+// Fields are dropped from the end until a field value's worth of room is
+// left for the description.
+func (t *DiscordTarget) embed(e *Event, a Attachment) (discordEmbed, []string) {
+	embed := discordEmbed{
+		Title: truncate(a.Title, discordTitleLimit),
+		Color: parseColor(a.Color),
+	}
+	if c, ok := t.colors[e.Type]; ok {
+		embed.Color = c
+	}
+	// Slack takes the event time as Unix seconds in ts, Discord as an
+	// ISO8601 timestamp
+	if a.TS != 0 {
+		embed.Timestamp = time.Unix(a.TS, 0).UTC().Format(time.RFC3339)
+	}
+	if a.Footer != "" {
+		embed.Footer = &discordFooter{Text: truncate(a.Footer, discordFooterLimit)}
+	}
+	for j, f := range a.Fields {
+		if j == discordFieldLimit {
+			break
+		}
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   orEmpty(truncate(f.Title, discordFieldNameLimit)),
+			Value:  orEmpty(truncate(f.Value, discordFieldValueLimit)),
+			Inline: f.Short,
+		})
+	}
+	for len(embed.Fields) > 0 && embedLen(embed) > discordTotalLimit-discordFieldValueLimit {
+		embed.Fields = embed.Fields[:len(embed.Fields)-1]
+	}
+	limit := discordTotalLimit - embedLen(embed)
+	if limit > discordDescriptionLimit {
+		limit = discordDescriptionLimit
+	}
+
+	lang := a.logLanguage(t.lang)
+	var chunks []string
+	switch {
+	case a.logs == "":
+		embed.Description = truncate(a.Text, limit)
+	case len(codeBlock(a.logs, lang)) <= limit:
+		embed.Description = codeBlock(a.logs, lang)
+	case t.overflow == DiscordTruncate:
+		embed.Description = codeBlock(tail(a.logs, limit-len(codeBlock("", lang))), lang)
+	default:
+		for _, chunk := range splitLines(a.logs, discordContentLimit-len(codeBlock("", lang))) {
+			chunks = append(chunks, codeBlock(chunk, lang))
+		}
+	}
+	return embed, chunks
+}
+
+// embedLen is the length of the text of embed counted into
+// discordTotalLimit
+func embedLen(embed discordEmbed) int {
+	n := len(embed.Title) + len(embed.Description)
+	for _, f := range embed.Fields {
+		n += len(f.Name) + len(f.Value)
+	}
+	if embed.Footer != nil {
+		n += len(embed.Footer.Text)
+	}
+	return n
+}
+
+func (t *DiscordTarget) post(ctx context.Context, u string, dm *discordMessage) error {
+	b, err := json.Marshal(dm)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, u, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// codeBlock fences s with language hint lang, breaking up fences inside s
+// so they can't end the block
+func codeBlock(s, lang string) string {
+	s = strings.ReplaceAll(s, codeFence, "`"+discordEmpty+"``")
+	return codeFence + lang + "\n" + strings.TrimSuffix(s, "\n") + "\n" + codeFence
+}
+
+// splitLines splits s into chunks of at most limit bytes, on line breaks
+// where possible. Fences are expanded by codeBlock, so the limit accounts
+// for the escaped form.
+func splitLines(s string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		for escapedLen(line) > limit {
+			flush()
+			n := cutPoint(line, limit)
+			chunks = append(chunks, line[:n])
+			line = line[n:]
+		}
+		if escapedLen(cur.String())+escapedLen(line) > limit {
+			flush()
+		}
+		cur.WriteString(line)
+	}
+	flush()
+	return chunks
+}
+
+func escapedLen(s string) int {
+	return len(s) + strings.Count(s, codeFence)*len(discordEmpty)
+}
+
+// cutPoint returns index at a rune boundary so that the escaped form of
+// s[:i] fits into limit bytes
+func cutPoint(s string, limit int) int {
+	i := limit / (1 + len(discordEmpty))
+	for i < len(s) && escapedLen(s[:i+1]) <= limit {
+		i++
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	i := limit - len("…")
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + "…"
+}
+
+// tail keeps the end of s which fits into limit bytes after codeBlock
+func tail(s string, limit int) string {
+	const marker = "…(truncated)\n"
+	limit -= len(marker)
+	i := len(s) - limit
+	if i < 0 {
+		i = 0
+	}
+	for i < len(s) && (escapedLen(s[i:]) > limit || !utf8.RuneStart(s[i])) {
+		i++
+	}
+	return marker + s[i:]
+}
+
+func orEmpty(s string) string {
+	if s == "" {
+		return discordEmpty
+	}
+	return s
+}
+
+// parseColor converts a "#rrggbb" color into Discord's integer color
+func parseColor(hex string) int {
+	c, err := strconv.ParseInt(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(c)
+}
+
+// parseDiscordColors reads DISCORD_<EVENT>_COLOR of each event type
+func parseDiscordColors(types []string) (map[string]int, error) {
+	colors := make(map[string]int)
+	for _, typ := range types {
+		key := fmt.Sprintf(DiscordColorEnvFormat, strings.ToUpper(typ))
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		c, err := parseColorValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		colors[typ] = c
+	}
+	return colors, nil
+}
+
+// parseColorValue parses a color given as hex ("#rrggbb" or "0xrrggbb") or
+// decimal
+func parseColorValue(v string) (int, error) {
+	var c int64
+	var err error
+	switch {
+	case strings.HasPrefix(v, "#"):
+		c, err = strconv.ParseInt(v[1:], 16, 32)
+	case strings.HasPrefix(v, "0x"), strings.HasPrefix(v, "0X"):
+		c, err = strconv.ParseInt(v[2:], 16, 32)
+	default:
+		c, err = strconv.ParseInt(v, 10, 32)
+	}
+	if err != nil || c < 0 || c > 0xffffff {
+		return 0, fmt.Errorf("%q is not a color", v)
+	}
+	return int(c), nil
+}
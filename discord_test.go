@@ -0,0 +1,188 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  string
+	}{
+		{s: "short", limit: 10, want: "short"},
+		{s: "exactly", limit: 7, want: "exactly"},
+		{s: "0123456789", limit: 8, want: "01234…"},
+		{s: "ééééé", limit: 8, want: "éé…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.limit); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	logs := strings.Repeat("line\n", 100)
+	for _, limit := range []int{20, 50, 100} {
+		got := tail(logs, limit)
+		if escapedLen(got) > limit {
+			t.Errorf("tail(%d) = %d bytes", limit, escapedLen(got))
+		}
+		if !strings.HasPrefix(got, "…(truncated)\n") || !strings.HasSuffix(logs, strings.TrimPrefix(got, "…(truncated)\n")) {
+			t.Errorf("tail(%d) = %q, want a marker and the end of logs", limit, got)
+		}
+	}
+	fenced := strings.Repeat("```", 50)
+	if got := tail(fenced, 40); escapedLen(got) > 40 {
+		t.Errorf("tail of fences = %d escaped bytes, want <= 40", escapedLen(got))
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		limit int
+	}{
+		{name: "lines", s: strings.Repeat("a line of logs\n", 50), limit: 100},
+		{name: "long line", s: strings.Repeat("x", 450), limit: 100},
+		{name: "fences", s: strings.Repeat("``` ", 100), limit: 50},
+		{name: "runes", s: strings.Repeat("é", 200), limit: 33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitLines(tt.s, tt.limit)
+			if got := strings.Join(chunks, ""); got != tt.s {
+				t.Fatalf("chunks don't add up to s")
+			}
+			for _, c := range chunks {
+				if escapedLen(c) > tt.limit {
+					t.Errorf("chunk of %d escaped bytes, over %d", escapedLen(c), tt.limit)
+				}
+				if !utf8.ValidString(c) {
+					t.Errorf("chunk %q is cut within a rune", c)
+				}
+			}
+		})
+	}
+}
+
+func TestCutPoint(t *testing.T) {
+	if got := cutPoint("0123456789", 4); got != 4 {
+		t.Errorf("cutPoint = %d, want 4", got)
+	}
+	if got := cutPoint("```x", 4); escapedLen("```x"[:got]) > 4 {
+		t.Errorf("cutPoint = %d, escaped over 4", got)
+	}
+	if got := cutPoint("éé", 3); got != 2 {
+		t.Errorf("cutPoint = %d, want 2 at a rune boundary", got)
+	}
+}
+
+func embedsLen(dm *discordMessage) int {
+	n := 0
+	for _, e := range dm.Embeds {
+		n += embedLen(e)
+	}
+	return n
+}
+
+func TestDiscordTranslateFieldLimits(t *testing.T) {
+	target := NewDiscordTarget(nil, DiscordSplit, DefaultDiscordMaxPosts, nil, "")
+	a := Attachment{
+		Title: strings.Repeat("t", 300),
+		Text:  "text",
+	}
+	for i := 0; i < 30; i++ {
+		a.Fields = append(a.Fields, Field{Title: strings.Repeat("n", 300), Value: strings.Repeat("v", 2000)})
+	}
+	dm, _ := target.translate(&Event{}, &Message{Text: strings.Repeat("c", 3000), Attachments: []Attachment{a}})
+	if len(dm.Content) > discordContentLimit {
+		t.Errorf("content = %d bytes", len(dm.Content))
+	}
+	embed := dm.Embeds[0]
+	if len(embed.Title) > discordTitleLimit {
+		t.Errorf("title = %d bytes", len(embed.Title))
+	}
+	if len(embed.Fields) == 0 || len(embed.Fields) > discordFieldLimit {
+		t.Errorf("%d fields", len(embed.Fields))
+	}
+	for _, f := range embed.Fields {
+		if len(f.Name) > discordFieldNameLimit || len(f.Value) > discordFieldValueLimit {
+			t.Errorf("field of %d and %d bytes", len(f.Name), len(f.Value))
+		}
+	}
+	if n := embedLen(embed); n > discordTotalLimit {
+		t.Errorf("embed = %d bytes, over %d", n, discordTotalLimit)
+	}
+	if embed.Description != "text" {
+		t.Errorf("description = %q, want text", embed.Description)
+	}
+}
+
+func TestDiscordTranslateEmbedLimit(t *testing.T) {
+	logs := strings.Repeat("a line of logs\n", 400)
+	for _, overflow := range []string{DiscordSplit, DiscordTruncate} {
+		t.Run(overflow, func(t *testing.T) {
+			target := NewDiscordTarget(nil, overflow, DefaultDiscordMaxPosts, nil, "")
+			a := Attachment{Title: "died", logs: logs, Fields: []Field{{Title: "raw event", Value: strings.Repeat("r", 1000)}}}
+			dm, followUps := target.translate(&Event{}, &Message{Attachments: []Attachment{a}})
+			embed := dm.Embeds[0]
+			if len(embed.Description) > discordDescriptionLimit || embedLen(embed) > discordTotalLimit {
+				t.Errorf("embed = %d bytes with a description of %d", embedLen(embed), len(embed.Description))
+			}
+			for _, f := range followUps {
+				if len(f.Content) > discordContentLimit {
+					t.Errorf("follow-up = %d bytes", len(f.Content))
+				}
+			}
+			if overflow == DiscordSplit && len(followUps) == 0 {
+				t.Error("logs not split into follow-ups")
+			}
+			if overflow == DiscordTruncate && (len(followUps) != 0 || embed.Description == "") {
+				t.Errorf("logs not truncated into the embed, %d follow-ups", len(followUps))
+			}
+		})
+	}
+}
+
+func TestDiscordTranslateTotalLimit(t *testing.T) {
+	target := NewDiscordTarget(nil, DiscordTruncate, 20, nil, "")
+	var m Message
+	for i := 0; i < 12; i++ {
+		m.Attachments = append(m.Attachments, Attachment{
+			Title:  "task died",
+			logs:   strings.Repeat("some logs\n", 300),
+			Fields: []Field{{Title: "exits", Value: "1, 1, 137"}},
+		})
+	}
+	dm, followUps := target.translate(&Event{}, &m)
+	embeds := 0
+	for _, post := range append([]*discordMessage{dm}, followUps...) {
+		if n := embedsLen(post); n > discordTotalLimit {
+			t.Errorf("post with %d bytes of embeds, over %d", n, discordTotalLimit)
+		}
+		if len(post.Embeds) > discordEmbedLimit {
+			t.Errorf("post with %d embeds", len(post.Embeds))
+		}
+		embeds += len(post.Embeds)
+	}
+	if embeds != len(m.Attachments) {
+		t.Errorf("%d embeds, want %d", embeds, len(m.Attachments))
+	}
+}
+
+func TestDiscordTranslateMaxPosts(t *testing.T) {
+	target := NewDiscordTarget(nil, DiscordTruncate, 2, nil, "")
+	var m Message
+	for i := 0; i < 5; i++ {
+		m.Attachments = append(m.Attachments, Attachment{Title: "task died", logs: strings.Repeat("some logs\n", 300)})
+	}
+	_, followUps := target.translate(&Event{}, &m)
+	if len(followUps) != 2 {
+		t.Errorf("%d follow-ups, want DISCORD_MAX_POSTS 2", len(followUps))
+	}
+}
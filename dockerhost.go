@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/cli/cli/connhelper"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// DockerHostEnv is key of DOCKER_HOST
+	DockerHostEnv = "DOCKER_HOST"
+	// sshPingTimeout bounds checking the SSH connection at startup
+	sshPingTimeout = 30 * time.Second
+)
+
+// sshAuthErrors are messages of ssh which tell that logging in failed
+var sshAuthErrors = []string{
+	"Permission denied",
+	"Host key verification failed",
+	"Too many authentication failures",
+	"no matching host key type found",
+}
+
+// newClient creates a client of the daemon at DOCKER_HOST, which may be a
+// ssh://user@host URL, or the local daemon by default
+func newClient(apiVersion string) (*client.Client, error) {
+	host := os.Getenv(DockerHostEnv)
+	if !strings.HasPrefix(host, "ssh://") {
+		return client.NewClientWithOpts(client.FromEnv, client.WithVersion(apiVersion))
+	}
+	helper, err := connhelper.GetConnectionHelper(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", DockerHostEnv, err)
+	}
+	cli, err := client.NewClientWithOpts(
+		client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{DialContext: helper.Dialer},
+		}),
+		client.WithHost(helper.Host),
+		client.WithDialContext(helper.Dialer),
+		client.WithVersion(apiVersion),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// ssh is only run on the first request, so check it works right away
+	ctx, cancel := context.WithTimeout(context.Background(), sshPingTimeout)
+	defer cancel()
+	if _, err := cli.Ping(ctx); err != nil {
+		cli.Close()
+		if isSSHAuthError(err) {
+			return nil, fmt.Errorf("ssh authentication to %s failed: %v", host, err)
+		}
+		return nil, fmt.Errorf("connecting to %s over ssh: %v", host, err)
+	}
+	return cli, nil
+}
+
+func isSSHAuthError(err error) bool {
+	for _, s := range sshAuthErrors {
+		if strings.Contains(err.Error(), s) {
+			return true
+		}
+	}
+	return false
+}
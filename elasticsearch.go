@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// ESURLEnv is key of ES_URL
+	ESURLEnv = "ES_URL"
+	// ESIndexEnv is key of ES_INDEX
+	ESIndexEnv = "ES_INDEX"
+	// ESAPIKeyEnv is key of ES_API_KEY
+	ESAPIKeyEnv = "ES_API_KEY"
+	// ESBatchSizeEnv is key of ES_BATCH_SIZE
+	ESBatchSizeEnv = "ES_BATCH_SIZE"
+	// ESFlushIntervalEnv is key of ES_FLUSH_INTERVAL
+	ESFlushIntervalEnv = "ES_FLUSH_INTERVAL"
+	// ESQueueSizeEnv is key of ES_QUEUE_SIZE
+	ESQueueSizeEnv = "ES_QUEUE_SIZE"
+	// ESMaxRetriesEnv is key of ES_MAX_RETRIES
+	ESMaxRetriesEnv = "ES_MAX_RETRIES"
+	// DefaultESIndex is index events are written to
+	DefaultESIndex = "docker-notify"
+	// DefaultESBatchSize is number of events written by one bulk request
+	DefaultESBatchSize = 100
+	// DefaultESFlushInterval is how long events wait for a full batch
+	DefaultESFlushInterval = 5 * time.Second
+	// DefaultESQueueSize is number of events waiting to be written before
+	// new events are dropped
+	DefaultESQueueSize = 1000
+	// DefaultESMaxRetries is number of retries of a failed bulk request
+	DefaultESMaxRetries = 3
+)
+
+// esDocument is an event as indexed into Elasticsearch
+type esDocument struct {
+	Timestamp     time.Time         `json:"@timestamp"`
+	Event         string            `json:"event_type"`
+	Severity      Severity          `json:"severity"`
+	ContainerID   string            `json:"container_id"`
+	ContainerName string            `json:"container_name"`
+	Image         string            `json:"image"`
+	ExitCode      *int              `json:"exit_code,omitempty"`
+	Host          string            `json:"host,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	TriggeredBy   string            `json:"triggered_by,omitempty"`
+}
+
+// ElasticsearchTarget indexes events into Elasticsearch or OpenSearch with
+// the bulk API. Events are queued and written in the background, so slow
+// clusters don't block the event loop; events are dropped when the queue is
+// full.
+type ElasticsearchTarget struct {
+	url        string
+	index      string
+	apiKey     string
+	host       string
+	batchSize  int
+	interval   time.Duration
+	maxRetries int
+
+	queue chan esDocument
+	done  chan struct{}
+}
+
+// NewElasticsearchTarget is constructor. It starts writing events in the
+// background.
+func NewElasticsearchTarget(u, index, apiKey, host string, batchSize, queueSize, maxRetries int, interval time.Duration) *ElasticsearchTarget {
+	t := &ElasticsearchTarget{
+		url:        strings.TrimSuffix(u, "/") + "/_bulk",
+		index:      index,
+		apiKey:     apiKey,
+		host:       host,
+		batchSize:  batchSize,
+		interval:   interval,
+		maxRetries: maxRetries,
+		queue:      make(chan esDocument, queueSize),
+		done:       make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Name returns name of target
+func (t *ElasticsearchTarget) Name() string {
+	return "elasticsearch"
+}
+
+// Send queues e, or each event of a batched m
+func (t *ElasticsearchTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	if e.Type == Summary {
+		return nil
+	}
+	events := []*Event{e}
+	if m != nil && len(m.events) > 0 {
+		events = m.events
+	}
+	for _, e := range events {
+		select {
+		case t.queue <- t.document(e):
+		default:
+			return errors.New("queue is full, dropping event")
+		}
+	}
+	return nil
+}
+
+func (t *ElasticsearchTarget) document(e *Event) esDocument {
+	doc := esDocument{
+		Timestamp:     e.Time,
+		Event:         e.Type,
+		Severity:      e.Severity,
+		ContainerID:   e.ID,
+		ContainerName: e.Name,
+		Image:         e.Image,
+		Host:          t.host,
+		Labels:        e.Labels,
+		CorrelationID: e.CorrelationID,
+		TriggeredBy:   e.TriggeredBy,
+	}
+	if code, err := strconv.Atoi(e.ExitCode); err == nil {
+		doc.ExitCode = &code
+	}
+	return doc
+}
+
+// run writes queued events when a batch is full or interval passed. When t
+// is closed, it writes the events queued so far and returns.
+func (t *ElasticsearchTarget) run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	var batch []esDocument
+	closed := false
+	for !closed {
+		select {
+		case doc := <-t.queue:
+			if batch = append(batch, doc); len(batch) < t.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-t.done:
+			closed = true
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := t.write(batch); err != nil {
+			log.Printf("%s: dropping %d events: %v", t.Name(), len(batch), err)
+		}
+		batch = nil
+	}
+}
+
+// Close writes the queued events and stops
+func (t *ElasticsearchTarget) Close() {
+	close(t.done)
+}
+
+// write bulk-indexes batch, retrying with backoff on errors of connections
+// and overloaded clusters
+func (t *ElasticsearchTarget) write(batch []esDocument) error {
+	var body bytes.Buffer
+	action, err := json.Marshal(map[string]map[string]string{"index": {"_index": t.index}})
+	if err != nil {
+		return err
+	}
+	for _, doc := range batch {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(b)
+		body.WriteByte('\n')
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := t.post(body.Bytes())
+		if err == nil || !retry || attempt >= t.maxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one bulk request. It reports whether a failed request should be
+// retried.
+func (t *ElasticsearchTarget) post(body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultEventDeadline)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+t.apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return true, fmt.Errorf("status %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("status %s: %s", resp.Status, b)
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return false, err
+	}
+	if !result.Errors {
+		return false, nil
+	}
+	failed := 0
+	var first json.RawMessage
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Error != nil {
+				if failed == 0 {
+					first = r.Error
+				}
+				failed++
+			}
+		}
+	}
+	return false, fmt.Errorf("%d events rejected: %s", failed, first)
+}
+
+// newElasticsearchTarget builds the target configured by ES_* keys. Events
+// are tagged with the name of the Docker host.
+func newElasticsearchTarget(cli *client.Client, u string) (*ElasticsearchTarget, error) {
+	index := os.Getenv(ESIndexEnv)
+	if index == "" {
+		index = DefaultESIndex
+	}
+	batchSize, err := parseInt(ESBatchSizeEnv, DefaultESBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if batchSize == 0 {
+		return nil, fmt.Errorf("%s must be positive", ESBatchSizeEnv)
+	}
+	queueSize, err := parseInt(ESQueueSizeEnv, DefaultESQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	if queueSize == 0 {
+		return nil, fmt.Errorf("%s must be positive", ESQueueSizeEnv)
+	}
+	maxRetries, err := parseInt(ESMaxRetriesEnv, DefaultESMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseDuration(ESFlushIntervalEnv, DefaultESFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	host, _ := os.Hostname()
+	if info, err := cli.Info(ctx); err == nil {
+		host = info.Name
+	}
+	return NewElasticsearchTarget(u, index, os.Getenv(ESAPIKeyEnv), host, batchSize, queueSize, maxRetries, interval), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// CorrelationIDEnv is key of CORRELATION_ID
+	CorrelationIDEnv = "CORRELATION_ID"
+	// CorrelationLabelEnv is key of CORRELATION_LABEL
+	CorrelationLabelEnv = "CORRELATION_LABEL"
+	// DefaultCorrelationLabel is label which carries a correlation ID
+	DefaultCorrelationLabel = "trace.id"
+	// EventTimeMaxSkewEnv is key of EVENT_TIME_MAX_SKEW
+	EventTimeMaxSkewEnv = "EVENT_TIME_MAX_SKEW"
+	// DefaultEventTimeMaxSkew is how far in the future event times are
+	// plausible
+	DefaultEventTimeMaxSkew = time.Hour
+)
+
+// minEventTime is the first release of Docker, events before are implausible
+var minEventTime = time.Date(2013, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+// Event is a container event as recorded by docker-notify
+type Event struct {
+	Time     time.Time         `json:"time"`
+	Type     string            `json:"type"`
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	ExitCode string            `json:"exit_code,omitempty"`
+	Severity Severity          `json:"severity"`
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	CorrelationID string `json:"correlation_id,omitempty"`
+	TriggeredBy   string `json:"triggered_by,omitempty"`
+
+	// targets are the only targets e is sent to when set by a rule
+	targets []string
+}
+
+// newEvent is constructor of Event from a docker event
+func newEvent(msg *events.Message, severity Severity) *Event {
+	labels := make(map[string]string)
+	for k, v := range msg.Actor.Attributes {
+		switch k {
+		case "name", "image", "exitCode":
+		default:
+			labels[k] = v
+		}
+	}
+	return &Event{
+		Time:     eventTime(msg),
+		Type:     eventType(msg),
+		ID:       msg.ID,
+		Name:     msg.Actor.Attributes["name"],
+		Image:    msg.From,
+		ExitCode: msg.Actor.Attributes["exitCode"],
+		Severity: severity,
+		Labels:   labels,
+	}
+}
+
+// attribute returns actor attribute key of the docker event of e, which is a
+// label or one of name, image and exitCode
+func (e *Event) attribute(key string) (string, bool) {
+	switch key {
+	case "name":
+		return e.Name, e.Name != ""
+	case "image":
+		return e.Image, e.Image != ""
+	case "exitCode":
+		return e.ExitCode, e.ExitCode != ""
+	}
+	v, ok := e.Labels[key]
+	return v, ok
+}
+
+// eventTime is when msg happened
+func eventTime(msg *events.Message) time.Time {
+	if msg.TimeNano == 0 {
+		return time.Unix(msg.Time, 0)
+	}
+	return time.Unix(0, msg.TimeNano)
+}
+
+// plausibleTime reports whether t of an event is between minEventTime and
+// maxSkew after now
+func plausibleTime(t, now time.Time, maxSkew time.Duration) bool {
+	return !t.Before(minEventTime) && !t.After(now.Add(maxSkew))
+}
+
+// fixEventTime replaces zero and implausible times of msg, e.g. from a daemon
+// with a broken clock, with now, so targets don't render or reject them
+func (c *Config) fixEventTime(msg *events.Message, now time.Time) {
+	t := eventTime(msg)
+	if plausibleTime(t, now, c.EventTimeMaxSkew) {
+		// Attachments take the time in seconds
+		msg.Time = t.Unix()
+		return
+	}
+	if msg.Time == 0 && msg.TimeNano == 0 {
+		log.Printf("%s event of %s has no time, using the current time", msg.Status, msg.ID)
+	} else {
+		log.Printf("%s event of %s has implausible time %s, using the current time", msg.Status, msg.ID, t.UTC().Format(time.RFC3339))
+	}
+	msg.Time = now.Unix()
+	msg.TimeNano = now.UnixNano()
+}
+
+// correlationID returns value of label of msg, or a new UUID when the label
+// is not set
+func correlationID(msg *events.Message, label string) string {
+	if id := msg.Actor.Attributes[label]; id != "" {
+		return id
+	}
+	return newUUID()
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// eventType returns type of msg, where health_status events are shortened
+// to their status, e.g. unhealthy
+func eventType(msg *events.Message) string {
+	if strings.HasPrefix(msg.Status, HealthStatusPrefix) {
+		return strings.TrimPrefix(msg.Status, HealthStatusPrefix)
+	}
+	return msg.Status
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestFixEventTime(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	valid := now.Add(-time.Minute)
+	config := &Config{EventTimeMaxSkew: DefaultEventTimeMaxSkew}
+	tests := []struct {
+		name string
+		msg  events.Message
+		want time.Time
+	}{
+		{
+			name: "valid",
+			msg:  events.Message{Time: valid.Unix(), TimeNano: valid.UnixNano()},
+			want: valid,
+		},
+		{
+			name: "seconds only",
+			msg:  events.Message{Time: valid.Unix()},
+			want: valid,
+		},
+		{
+			name: "slightly in the future",
+			msg:  events.Message{TimeNano: now.Add(time.Minute).UnixNano()},
+			want: now.Add(time.Minute),
+		},
+		{
+			name: "zero",
+			msg:  events.Message{},
+			want: now,
+		},
+		{
+			name: "far future",
+			msg:  events.Message{TimeNano: now.AddDate(10, 0, 0).UnixNano()},
+			want: now,
+		},
+		{
+			name: "before docker",
+			msg:  events.Message{Time: 1},
+			want: now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			config.fixEventTime(&msg, now)
+			if got := eventTime(&msg); !got.Equal(tt.want) {
+				t.Errorf("eventTime() = %v, want %v", got, tt.want)
+			}
+			if got := msg.Time; got != tt.want.Unix() {
+				t.Errorf("Time = %d, want %d", got, tt.want.Unix())
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// ExtraFieldsEnv is key of EXTRA_FIELDS
+	ExtraFieldsEnv = "EXTRA_FIELDS"
+	// LabelFieldsEnv is key of LABEL_FIELDS
+	LabelFieldsEnv = "LABEL_FIELDS"
+	// MaxFieldsEnv is key of MAX_FIELDS
+	MaxFieldsEnv = "MAX_FIELDS"
+	// FieldPriorityEnv is key of FIELD_PRIORITY
+	FieldPriorityEnv = "FIELD_PRIORITY"
+	// CorrelationIDField is title of the correlation ID field
+	CorrelationIDField = "correlation id"
+)
+
+// parseExtraFields parses comma separated title=value pairs
+func parseExtraFields(s string) ([]Field, error) {
+	var fields []Field
+	for _, kv := range splitList(s) {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %q, want title=value", ExtraFieldsEnv, kv)
+		}
+		fields = append(fields, Field{
+			Title: kv[:i],
+			Value: kv[i+1:],
+			Short: true,
+		})
+	}
+	return fields, nil
+}
+
+// addFields appends configured fields to the first attachment and caps them
+func (c *Config) addFields(m *Message, e *Event) {
+	if len(m.Attachments) == 0 {
+		return
+	}
+	a := &m.Attachments[0]
+	a.Fields = append(a.Fields, c.ExtraFields...)
+	for _, key := range c.LabelFields {
+		if v, ok := e.attribute(key); ok {
+			a.Fields = append(a.Fields, Field{Title: key, Value: v, Short: true})
+		}
+	}
+	if e.CorrelationID != "" {
+		a.Fields = append(a.Fields, Field{Title: CorrelationIDField, Value: e.CorrelationID, Short: true})
+	}
+	a.Fields = limitFields(a.Fields, c.MaxFields, c.FieldPriority)
+}
+
+// limitFields keeps at most max fields, preferring titles listed in priority.
+// Dropped fields are summarized with a "+N more" note. max 0 means no limit.
+func limitFields(fields []Field, max int, priority []string) []Field {
+	if max <= 0 || len(fields) <= max {
+		return fields
+	}
+	rank := func(title string) int {
+		for i, p := range priority {
+			if p == title {
+				return i
+			}
+		}
+		return len(priority)
+	}
+	sorted := make([]Field, len(fields))
+	copy(sorted, fields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank(sorted[i].Title) < rank(sorted[j].Title)
+	})
+	dropped := len(sorted) - max
+	return append(sorted[:max], Field{
+		Value: fmt.Sprintf("+%d more", dropped),
+	})
+}
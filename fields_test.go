@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestAddFieldsLabelFields(t *testing.T) {
+	msg := &events.Message{
+		Status: Die,
+		ID:     "abc",
+		From:   "nginx:1.21",
+		Actor: events.Actor{
+			ID: "abc",
+			Attributes: map[string]string{
+				"name":     "web",
+				"image":    "nginx:1.21",
+				"exitCode": "1",
+				"team":     "core",
+			},
+		},
+	}
+	config := &Config{LabelFields: []string{"name", "image", "exitCode", "team", "missing"}}
+	m := &Message{Attachments: []Attachment{{}}}
+	config.addFields(m, newEvent(msg, Critical))
+	want := []Field{
+		{Title: "name", Value: "web", Short: true},
+		{Title: "image", Value: "nginx:1.21", Short: true},
+		{Title: "exitCode", Value: "1", Short: true},
+		{Title: "team", Value: "core", Short: true},
+	}
+	if got := m.Attachments[0].Fields; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+}
+
+func TestAddFieldsNoExitCode(t *testing.T) {
+	msg := &events.Message{
+		Status: Start,
+		Actor:  events.Actor{Attributes: map[string]string{"name": "web"}},
+	}
+	config := &Config{LabelFields: []string{"exitCode"}}
+	m := &Message{Attachments: []Attachment{{}}}
+	config.addFields(m, newEvent(msg, Info))
+	if got := m.Attachments[0].Fields; len(got) != 0 {
+		t.Errorf("fields = %+v, want none", got)
+	}
+}
+
+func TestLimitFields(t *testing.T) {
+	fields := []Field{{Title: "a"}, {Title: "b"}, {Title: "c"}, {Title: "d"}}
+	titles := func(fields []Field) []string {
+		var titles []string
+		for _, f := range fields {
+			titles = append(titles, f.Title+f.Value)
+		}
+		return titles
+	}
+	tests := []struct {
+		name     string
+		max      int
+		priority []string
+		want     []string
+	}{
+		{name: "no limit", max: 0, want: []string{"a", "b", "c", "d"}},
+		{name: "within limit", max: 4, want: []string{"a", "b", "c", "d"}},
+		{name: "in order", max: 2, want: []string{"a", "b", "+2 more"}},
+		{name: "priority first", max: 2, priority: []string{"d", "c"}, want: []string{"d", "c", "+2 more"}},
+		{name: "priority then order", max: 3, priority: []string{"c"}, want: []string{"c", "a", "b", "+1 more"}},
+		{name: "unknown priority", max: 1, priority: []string{"x"}, want: []string{"a", "+3 more"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append([]Field(nil), fields...)
+			got := titles(limitFields(in, tt.max, tt.priority))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("limitFields() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(in, fields) {
+				t.Errorf("limitFields() modified its input to %v", in)
+			}
+		})
+	}
+}
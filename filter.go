@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// IgnoreImagesEnv is key of IGNORE_IMAGES
+	IgnoreImagesEnv = "IGNORE_IMAGES"
+	// IgnoreLabel is label to opt a container out of notifications
+	IgnoreLabel = "docker-notify.ignore"
+	// RequireOptInEnv is key of REQUIRE_OPT_IN
+	RequireOptInEnv = "REQUIRE_OPT_IN"
+	// OptInLabelEnv is key of OPT_IN_LABEL
+	OptInLabelEnv = "OPT_IN_LABEL"
+	// DefaultOptInLabel is label to opt a container in notifications
+	DefaultOptInLabel = "docker-notify"
+	// BuildxContainerPrefix is name prefix of containers created by buildx
+	BuildxContainerPrefix = "buildx_buildkit_"
+)
+
+// DefaultIgnoreImages are image patterns of transient build/pull helpers
+var DefaultIgnoreImages = []string{
+	"moby/buildkit*",
+	"docker.io/moby/buildkit*",
+}
+
+// Filter reports whether a notification should be sent for msg
+type Filter func(msg *events.Message) bool
+
+// IgnoreImagesFilter skips containers whose image matches one of patterns
+func IgnoreImagesFilter(patterns []string) Filter {
+	return func(msg *events.Message) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, msg.From); ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// IgnoreHelpersFilter skips containers which are part of a build
+func IgnoreHelpersFilter(msg *events.Message) bool {
+	if msg.Actor.Attributes[IgnoreLabel] == "true" {
+		return false
+	}
+	return !strings.HasPrefix(msg.Actor.Attributes["name"], BuildxContainerPrefix)
+}
+
+// OptInFilter only passes containers whose label is true
+func OptInFilter(label string) Filter {
+	return func(msg *events.Message) bool {
+		ok, _ := strconv.ParseBool(msg.Actor.Attributes[label])
+		return ok
+	}
+}
+
+func parseImagePatterns(s string) ([]string, error) {
+	patterns := splitList(s)
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
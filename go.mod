@@ -2,7 +2,11 @@ module github.com/lon9/docker-notify
 
 go 1.17
 
-require github.com/docker/docker v20.10.11+incompatible
+require (
+	github.com/antonmedv/expr v1.9.0
+	github.com/docker/cli v20.10.12+incompatible
+	github.com/docker/docker v20.10.11+incompatible
+)
 
 require (
 	github.com/Microsoft/go-winio v0.4.17 // indirect
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// HealthEventsEnv is key of HEALTH_EVENTS
+	HealthEventsEnv = "HEALTH_EVENTS"
+	// HealthGraceEnv is key of HEALTH_GRACE
+	HealthGraceEnv = "HEALTH_GRACE"
+	// HealthStatusPrefix is prefix of status of health_status events
+	HealthStatusPrefix = "health_status: "
+	// DefaultHealthGrace is how long a container must stay unhealthy
+	DefaultHealthGrace = 30 * time.Second
+)
+
+// HealthDebouncer holds back health events of flapping containers. An
+// unhealthy container is notified when it stays unhealthy for grace, and
+// its recovery only when it was notified unhealthy.
+type HealthDebouncer struct {
+	grace     time.Duration
+	cache     *ContainerCache
+	key       *KeyTemplate
+	confirmed chan events.Message
+
+	mu     sync.Mutex
+	timers map[*time.Timer]struct{}
+	done   chan struct{}
+}
+
+// NewHealthDebouncer is constructor. Health is tracked per key of events.
+func NewHealthDebouncer(grace time.Duration, cache *ContainerCache, key *KeyTemplate) *HealthDebouncer {
+	return &HealthDebouncer{
+		grace:     grace,
+		cache:     cache,
+		key:       key,
+		confirmed: make(chan events.Message, 16),
+		timers:    make(map[*time.Timer]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Close stops the grace periods still running, so their events are never
+// confirmed
+func (h *HealthDebouncer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	close(h.done)
+	for timer := range h.timers {
+		timer.Stop()
+	}
+	h.timers = nil
+}
+
+// Confirmed returns channel of unhealthy events which outlasted grace. It
+// is nil when h is nil, so health events are never received.
+func (h *HealthDebouncer) Confirmed() <-chan events.Message {
+	if h == nil {
+		return nil
+	}
+	return h.confirmed
+}
+
+// Pass reports whether msg should be processed now. Only transitions of
+// the health status are processed, as some healthchecks emit an event with
+// the same status on every check. Unhealthy events are sent to Confirmed
+// later when the container is still unhealthy.
+func (h *HealthDebouncer) Pass(msg *events.Message) bool {
+	if h == nil {
+		return true
+	}
+	status := eventType(msg)
+	if status != Unhealthy && status != Healthy {
+		return true
+	}
+	key := h.key.Key(newEvent(msg, 0))
+	changed, since := h.cache.SetHealth(key, status)
+	if !changed {
+		return false
+	}
+	switch status {
+	case Unhealthy:
+		if h.grace == 0 {
+			return h.cache.ReportUnhealthy(key, since)
+		}
+		h.confirmLater(*msg, key, since)
+		return false
+	default:
+		return h.cache.Recovered(key)
+	}
+}
+
+// confirmLater sends msg to Confirmed after grace when the container is still
+// unhealthy. When nothing receives it, e.g. after a reload turned off
+// HEALTH_EVENTS, msg is dropped instead of blocking for good.
+func (h *HealthDebouncer) confirmLater(msg events.Message, key string, since time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timers == nil {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(h.grace, func() {
+		h.mu.Lock()
+		delete(h.timers, timer)
+		h.mu.Unlock()
+		if !h.cache.ReportUnhealthy(key, since) {
+			return
+		}
+		select {
+		case h.confirmed <- msg:
+		case <-h.done:
+		default:
+			log.Printf("%s: dropped unhealthy event of %s, nothing receives it", HealthEventsEnv, msg.Actor.Attributes["name"])
+		}
+	})
+	h.timers[timer] = struct{}{}
+}
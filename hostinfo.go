@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// OOMHostInfoEnv is key of OOM_HOST_INFO
+	OOMHostInfoEnv = "OOM_HOST_INFO"
+	// HostInfoTTLEnv is key of HOST_INFO_TTL
+	HostInfoTTLEnv = "HOST_INFO_TTL"
+	// DefaultHostInfoTTL is how long the daemon info is cached
+	DefaultHostInfoTTL = 30 * time.Second
+	// HostMemoryField is title of the host memory field
+	HostMemoryField = "host"
+	// meminfoPath is where the kernel reports memory of the host, which is
+	// also the host's inside a container
+	meminfoPath = "/proc/meminfo"
+)
+
+// InfoCache caches the daemon info, which is relatively expensive to get
+type InfoCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	info      types.Info
+	fetched   time.Time
+	available int64
+	read      time.Time
+}
+
+// NewInfoCache is constructor
+func NewInfoCache(ttl time.Duration) *InfoCache {
+	return &InfoCache{ttl: ttl}
+}
+
+// Info returns the daemon info, fetching it when the cached one is stale
+func (c *InfoCache) Info(ctx context.Context, cli *client.Client) (types.Info, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetched.IsZero() && time.Since(c.fetched) < c.ttl {
+		return c.info, nil
+	}
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return types.Info{}, err
+	}
+	c.info = info
+	c.fetched = time.Now()
+	return info, nil
+}
+
+// MemAvailable returns how much memory the host has available, reading it
+// again when the cached one is stale
+func (c *InfoCache) MemAvailable() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.read.IsZero() && time.Since(c.read) < c.ttl {
+		return c.available, nil
+	}
+	f, err := os.Open(meminfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	available, err := parseMemAvailable(f)
+	if err != nil {
+		return 0, err
+	}
+	c.available = available
+	c.read = time.Now()
+	return available, nil
+}
+
+// parseMemAvailable reads MemAvailable in bytes from /proc/meminfo
+func parseMemAvailable(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemAvailable %q: %v", fields[1], err)
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemAvailable in %s", meminfoPath)
+}
+
+// formatBytes formats n like "15.6 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatMemory formats available memory of total like
+// "1.2 GiB of 15.6 GiB available (8%)", or total alone when available is
+// unknown
+func formatMemory(available, total int64) string {
+	if available < 0 || total <= 0 {
+		return formatBytes(total) + " memory"
+	}
+	return fmt.Sprintf("%s of %s available (%d%%)", formatBytes(available), formatBytes(total), available*100/total)
+}
+
+// addHostInfo shows how much memory the host has left and how many
+// containers share it, to tell a container limit breach from host exhaustion
+func (c *Config) addHostInfo(ctx context.Context, cli *client.Client, m *Message) {
+	if c.HostInfo == nil {
+		return
+	}
+	info, err := c.HostInfo.Info(ctx, cli)
+	if err != nil {
+		log.Printf("failed to get host info: %v", err)
+		return
+	}
+	available, err := c.HostInfo.MemAvailable()
+	if err != nil {
+		log.Printf("failed to get available host memory: %v", err)
+		available = -1
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: HostMemoryField,
+		Value: fmt.Sprintf("%s, %d CPUs, %d containers running", formatMemory(available, info.MemTotal), info.NCPU, info.ContainersRunning),
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemAvailable(t *testing.T) {
+	meminfo := "MemTotal:       16303428 kB\nMemFree:         1021236 kB\nMemAvailable:    1276032 kB\nBuffers:          120300 kB\n"
+	got, err := parseMemAvailable(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1276032 * 1024); got != want {
+		t.Errorf("parseMemAvailable = %d, want %d", got, want)
+	}
+
+	for _, meminfo := range []string{"MemTotal:       16303428 kB\n", "MemAvailable:    many kB\n"} {
+		if _, err := parseMemAvailable(strings.NewReader(meminfo)); err == nil {
+			t.Errorf("parseMemAvailable(%q) succeeded, want error", meminfo)
+		}
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	tests := []struct {
+		available, total int64
+		want             string
+	}{
+		{available: gib, total: 16 * gib, want: "1.0 GiB of 16.0 GiB available (6%)"},
+		{available: 8 * gib, total: 16 * gib, want: "8.0 GiB of 16.0 GiB available (50%)"},
+		{available: -1, total: 16 * gib, want: "16.0 GiB memory"},
+	}
+	for _, tt := range tests {
+		if got := formatMemory(tt.available, tt.total); got != tt.want {
+			t.Errorf("formatMemory(%d, %d) = %q, want %q", tt.available, tt.total, got, tt.want)
+		}
+	}
+}
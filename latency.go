@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TargetLatencySLOEnvFormat is format of <TARGET>_LATENCY_SLO keys, e.g. SLACK_LATENCY_SLO=2s
+	TargetLatencySLOEnvFormat = "%s_LATENCY_SLO"
+	// LatencySamplesEnv is key of LATENCY_SAMPLES
+	LatencySamplesEnv = "LATENCY_SAMPLES"
+	// LatencyAlertTargetEnv is key of LATENCY_ALERT_TARGET
+	LatencyAlertTargetEnv = "LATENCY_ALERT_TARGET"
+	// DefaultLatencySamples is number of sends the average latency is taken of
+	DefaultLatencySamples = 10
+)
+
+// LatencyTarget measures how long a target takes to send messages and warns
+// when the average of the last sends exceeds slo
+type LatencyTarget struct {
+	Target
+	slo   time.Duration
+	alert Target
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+	slow    bool
+}
+
+// NewLatencyTarget is constructor. The average is taken of samples sends.
+func NewLatencyTarget(t Target, slo time.Duration, samples int) *LatencyTarget {
+	return &LatencyTarget{
+		Target:  t,
+		slo:     slo,
+		samples: make([]time.Duration, samples),
+	}
+}
+
+// Send sends m and records how long it took
+func (t *LatencyTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	start := time.Now()
+	err := t.Target.Send(ctx, e, m)
+	t.record(time.Since(start))
+	return err
+}
+
+func (t *LatencyTarget) record(d time.Duration) {
+	t.mu.Lock()
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+	t.full = t.full || t.next == 0
+	avg := t.average().Round(time.Millisecond)
+	// Only a full window of slow sends is consistently slow
+	changed := t.full && (avg > t.slo) != t.slow
+	if changed {
+		t.slow = !t.slow
+	}
+	slow := t.slow
+	t.mu.Unlock()
+	if !changed {
+		return
+	}
+	var text string
+	if slow {
+		text = fmt.Sprintf("%s: average latency %s of the last %d messages exceeds %s", t.Name(), avg, len(t.samples), t.slo)
+	} else {
+		text = fmt.Sprintf("%s: average latency %s is back within %s", t.Name(), avg, t.slo)
+	}
+	log.Println(text)
+	if t.alert != nil {
+		go t.sendAlert(text, slow)
+	}
+}
+
+// average is average latency of the recorded samples. t.mu must be held.
+func (t *LatencyTarget) average() time.Duration {
+	n := len(t.samples)
+	if !t.full {
+		n = t.next
+	}
+	if n == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range t.samples[:n] {
+		sum += d
+	}
+	return sum / time.Duration(n)
+}
+
+// sendAlert tells the alert target that t became slow or recovered
+func (t *LatencyTarget) sendAlert(text string, slow bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultEventDeadline)
+	defer cancel()
+	now := time.Now()
+	severity, color := Info, StartColor
+	if slow {
+		severity, color = Warning, SummaryColor
+	}
+	m := &Message{
+		Attachments: []Attachment{
+			{
+				Title: "webhook latency",
+				Text:  text,
+				Color: color,
+				TS:    now.Unix(),
+			},
+		},
+	}
+	if err := t.alert.Send(ctx, &Event{Time: now, Type: Summary, Severity: severity}, m); err != nil {
+		log.Printf("%s: %v", LatencyAlertTargetEnv, err)
+	}
+}
+
+// LatencyState is state of a LatencyTarget
+type LatencyState struct {
+	SLO     string `json:"slo"`
+	Average string `json:"average"`
+	Slow    bool   `json:"slow"`
+}
+
+// State returns the average latency
+func (t *LatencyTarget) State() LatencyState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return LatencyState{
+		SLO:     t.slo.String(),
+		Average: t.average().Round(time.Millisecond).String(),
+		Slow:    t.slow,
+	}
+}
+
+// measureTargets wraps targets which have <TARGET>_LATENCY_SLO set
+func measureTargets(targets []Target) ([]Target, error) {
+	samples, err := parseInt(LatencySamplesEnv, DefaultLatencySamples)
+	if err != nil {
+		return nil, err
+	}
+	if samples == 0 {
+		return nil, fmt.Errorf("%s must be positive", LatencySamplesEnv)
+	}
+	for i, t := range targets {
+		slo, err := parseDuration(fmt.Sprintf(TargetLatencySLOEnvFormat, strings.ToUpper(t.Name())), 0)
+		if err != nil {
+			return nil, err
+		}
+		if slo > 0 {
+			targets[i] = NewLatencyTarget(t, slo, samples)
+		}
+	}
+	return targets, nil
+}
+
+// latencyTarget returns t as LatencyTarget, also when it is rate limited,
+// or nil
+func latencyTarget(t Target) *LatencyTarget {
+	if limited, ok := t.(*RateLimitedTarget); ok {
+		t = limited.Target
+	}
+	measured, _ := t.(*LatencyTarget)
+	return measured
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// LogFileEnv is key of LOG_FILE
+	LogFileEnv = "LOG_FILE"
+	// LogFileMaxBytesEnv is key of LOG_FILE_MAX_BYTES
+	LogFileMaxBytesEnv = "LOG_FILE_MAX_BYTES"
+	// LogFileBackupsEnv is key of LOG_FILE_BACKUPS
+	LogFileBackupsEnv = "LOG_FILE_BACKUPS"
+	// DefaultLogFileMaxBytes is size of log file which triggers rotation
+	DefaultLogFileMaxBytes = 10 * 1024 * 1024
+	// DefaultLogFileBackups is number of rotated log files to keep
+	DefaultLogFileBackups = 3
+)
+
+// LogFileTarget appends events to a file as JSON lines
+type LogFileTarget struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewLogFileTarget is constructor
+func NewLogFileTarget(path string, maxBytes int64, backups int) (*LogFileTarget, error) {
+	t := &LogFileTarget{
+		path:     path,
+		maxBytes: maxBytes,
+		backups:  backups,
+	}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Name returns name of target
+func (t *LogFileTarget) Name() string {
+	return "logfile"
+}
+
+// Send appends e, or each event of a batched m, to the file, rotating it
+// when it grows too large
+func (t *LogFileTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	events := []*Event{e}
+	if m != nil && len(m.events) > 0 {
+		events = m.events
+	}
+	var b []byte
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.maxBytes > 0 && t.size > 0 && t.size+int64(len(b)) > t.maxBytes {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := t.file.Write(b)
+	t.size += int64(n)
+	return err
+}
+
+// Close closes the file
+func (t *LogFileTarget) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.file.Close()
+}
+
+func (t *LogFileTarget) open() error {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	t.file = f
+	t.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and reopens path.
+// It must be called with mu held.
+func (t *LogFileTarget) rotate() error {
+	t.file.Close()
+	err := t.shift()
+	if oerr := t.open(); oerr != nil {
+		return oerr
+	}
+	return err
+}
+
+func (t *LogFileTarget) shift() error {
+	if t.backups <= 0 {
+		return os.Truncate(t.path, 0)
+	}
+	for i := t.backups - 1; i > 0; i-- {
+		src := fmt.Sprintf("%s.%d", t.path, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", t.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(t.path, t.path+".1")
+}
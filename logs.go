@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// LogBinaryModeEnv is key of LOG_BINARY_MODE
+	LogBinaryModeEnv = "LOG_BINARY_MODE"
+	// LogHexBytesEnv is key of LOG_HEX_BYTES
+	LogHexBytesEnv = "LOG_HEX_BYTES"
+	// MinLogBytesEnv is key of MIN_LOG_BYTES
+	MinLogBytesEnv = "MIN_LOG_BYTES"
+	// BinaryReplace replaces invalid UTF-8 and control characters in logs
+	BinaryReplace = "replace"
+	// BinaryHex attaches a hex dump of binary logs
+	BinaryHex = "hex"
+	// LogErrorExtractEnv is key of LOG_ERROR_EXTRACT
+	LogErrorExtractEnv = "LOG_ERROR_EXTRACT"
+	// LogErrorPatternEnv is key of LOG_ERROR_PATTERN
+	LogErrorPatternEnv = "LOG_ERROR_PATTERN"
+	// DefaultLogErrorPattern matches lines which look like an error
+	DefaultLogErrorPattern = `(?i)\b(error|panic|fatal|exception|traceback)\b`
+	// ErrorLineField is title of the field of the first error line
+	ErrorLineField = "first error"
+	// DefaultLogHexBytes is number of bytes in hex dumps of binary logs
+	DefaultLogHexBytes = 256
+	// TargetLogLanguageEnvFormat is format of <TARGET>_LOG_LANGUAGE keys, e.g. DISCORD_LOG_LANGUAGE=auto
+	TargetLogLanguageEnvFormat = "%s_LOG_LANGUAGE"
+	// LogLanguageAuto hints json for JSON logs and nothing otherwise
+	LogLanguageAuto = "auto"
+
+	stdHeaderLen = 8
+)
+
+// demux strips the stream headers which prefix each frame of logs of
+// containers without a TTY. b is returned as is when it isn't multiplexed.
+func demux(b []byte) []byte {
+	var out bytes.Buffer
+	for rest := b; len(rest) > 0; {
+		if len(rest) < stdHeaderLen || rest[0] > 2 || rest[1] != 0 || rest[2] != 0 || rest[3] != 0 {
+			return b
+		}
+		size := int(binary.BigEndian.Uint32(rest[4:stdHeaderLen]))
+		if size > len(rest)-stdHeaderLen {
+			return b
+		}
+		out.Write(rest[stdHeaderLen : stdHeaderLen+size])
+		rest = rest[stdHeaderLen+size:]
+	}
+	return out.Bytes()
+}
+
+// isBinary reports whether b is not text
+func isBinary(b []byte) bool {
+	return !utf8.Valid(b) || bytes.IndexByte(b, 0) >= 0
+}
+
+// sanitizeLogs makes b safe to embed into a message according to
+// LOG_BINARY_MODE
+func (c *Config) sanitizeLogs(b []byte) string {
+	if !isBinary(b) {
+		return string(b)
+	}
+	if c.LogBinaryMode == BinaryHex {
+		n := len(b)
+		if c.LogHexBytes > 0 && n > c.LogHexBytes {
+			n = c.LogHexBytes
+		}
+		return fmt.Sprintf("binary output, first %d of %d bytes:\n%s", n, len(b), hex.Dump(b[:n]))
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\n' && r != '\t' && r != '\r' {
+			return utf8.RuneError
+		}
+		return r
+	}, strings.ToValidUTF8(string(b), string(utf8.RuneError)))
+}
+
+// firstMatch returns the first line of logs which matches re
+func firstMatch(logs string, re *regexp.Regexp) string {
+	for _, line := range strings.Split(logs, "\n") {
+		if re.MatchString(line) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// isJSONLines reports whether each non-empty line of logs is a JSON object
+// or array
+func isJSONLines(logs string) bool {
+	found := false
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if (line[0] != '{' && line[0] != '[') || !json.Valid([]byte(line)) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+var logLanguagePattern = regexp.MustCompile(`^[A-Za-z0-9_+-]*$`)
+
+// parseLogLanguage reads <TARGET>_LOG_LANGUAGE of target name
+func parseLogLanguage(name string) (string, error) {
+	key := fmt.Sprintf(TargetLogLanguageEnvFormat, strings.ToUpper(name))
+	lang := os.Getenv(key)
+	if !logLanguagePattern.MatchString(lang) {
+		return "", fmt.Errorf("%s must be %s or a language like json", key, LogLanguageAuto)
+	}
+	return lang, nil
+}
+
+// logLanguage resolves the language hint of the logs of a
+func (a *Attachment) logLanguage(hint string) string {
+	if hint != LogLanguageAuto {
+		return hint
+	}
+	if a.jsonLogs {
+		return "json"
+	}
+	return ""
+}
+
+// withLogLanguage returns m with the language hint added to the code blocks
+// of logs, or m as is when there is no hint
+func withLogLanguage(m *Message, hint string) *Message {
+	if hint == "" {
+		return m
+	}
+	copied := *m
+	copied.Attachments = make([]Attachment, len(m.Attachments))
+	for i, a := range m.Attachments {
+		if lang := a.logLanguage(hint); a.logs != "" && lang != "" {
+			a.Text = "```" + lang + "\n" + a.logs + "```"
+		}
+		copied.Attachments[i] = a
+	}
+	return &copied
+}
+
+// isLogsUnsupported reports whether err tells that the logging driver of the
+// container can't be read, e.g. syslog or none
+func isLogsUnsupported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "does not support reading")
+}
+
+// logsUnavailable returns a note telling the logs can't be attached, with
+// the logging driver if it can be inspected
+func logsUnavailable(ctx context.Context, cli *client.Client, id string) string {
+	info, err := cli.ContainerInspect(ctx, id)
+	if err != nil || info.HostConfig == nil || info.HostConfig.LogConfig.Type == "" {
+		return "logs unavailable"
+	}
+	return fmt.Sprintf("logs unavailable (driver: %s)", info.HostConfig.LogConfig.Type)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// frame is a frame of a multiplexed log stream
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, stdHeaderLen)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestDemux(t *testing.T) {
+	var multiplexed []byte
+	multiplexed = append(multiplexed, frame(1, "out\n")...)
+	multiplexed = append(multiplexed, frame(2, "err\n")...)
+	multiplexed = append(multiplexed, frame(1, "")...)
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{name: "multiplexed", in: multiplexed, want: "out\nerr\n"},
+		{name: "tty", in: []byte("plain output\n"), want: "plain output\n"},
+		{name: "short", in: []byte{1, 0}, want: "\x01\x00"},
+		{name: "bad stream", in: append([]byte{3}, frame(1, "x")[1:]...), want: "\x03\x00\x00\x00\x00\x00\x00\x01x"},
+		{name: "truncated frame", in: frame(1, "payload")[:10], want: string(frame(1, "payload")[:10])},
+		{name: "empty", in: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(demux(tt.in)); got != tt.want {
+				t.Errorf("demux() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeLogs(t *testing.T) {
+	binaryLogs := []byte("ok\x00\xff\xfe\x01end")
+	tests := []struct {
+		name   string
+		config Config
+		in     []byte
+		want   string
+	}{
+		{name: "text", config: Config{LogBinaryMode: BinaryReplace}, in: []byte("line\ttab\r\n"), want: "line\ttab\r\n"},
+		{name: "replace", config: Config{LogBinaryMode: BinaryReplace}, in: binaryLogs, want: "ok���end"},
+		{name: "hex", config: Config{LogBinaryMode: BinaryHex}, in: binaryLogs, want: "binary output, first 9 of 9 bytes:\n"},
+		{name: "hex limited", config: Config{LogBinaryMode: BinaryHex, LogHexBytes: 4}, in: binaryLogs, want: "binary output, first 4 of 9 bytes:\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.sanitizeLogs(tt.in)
+			if tt.config.LogBinaryMode == BinaryHex && isBinary(tt.in) {
+				if !strings.HasPrefix(got, tt.want) {
+					t.Errorf("sanitizeLogs() = %q, want prefix %q", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("sanitizeLogs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
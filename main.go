@@ -1,16 +1,19 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
@@ -22,6 +25,20 @@ const (
 	Start = "start"
 	// Die is identifier of die event
 	Die = "die"
+	// EventDeadlineEnv is key of EVENT_DEADLINE
+	EventDeadlineEnv = "EVENT_DEADLINE"
+	// DefaultEventDeadline bounds processing and delivery of an event
+	DefaultEventDeadline = time.Minute
+	// OOM is identifier of out of memory event
+	OOM = "oom"
+	// Unhealthy is identifier of health_status: unhealthy event
+	Unhealthy = "unhealthy"
+	// Healthy is identifier of health_status: healthy event
+	Healthy = "healthy"
+	// Create is identifier of create event
+	Create = "create"
+	// Destroy is identifier of destroy event
+	Destroy = "destroy"
 	// SlackURLEnv is key of SLACK_URL
 	SlackURLEnv = "SLACK_URL"
 	// DiscordURLEnv is key of DISCORD_URL
@@ -32,86 +49,586 @@ const (
 	DieColor = "#c62828"
 )
 
+// EventTypes are container events which are notified
+var EventTypes = []string{Start, Die, OOM, Unhealthy, Healthy}
+
 // Config is struct of config
 type Config struct {
 	SlackURL   string
 	DiscordURL string
+	Targets    []Target      `json:"-"`
+	Switches   *TargetSwitch `json:"-"`
+	Filters    []Filter      `json:"-"`
+	// FilterNames describe Filters
+	FilterNames []string
+
+	Routes Routes
+	Rules  []*Rule
+
+	ExtraFields   []Field
+	LabelFields   []string
+	MaxFields     int
+	FieldPriority []string
+
+	EventsMode   string
+	PollInterval time.Duration
+
+	LogMinSeverity   Severity
+	LogStreams       map[Severity]LogStreams
+	SuccessExitCodes []int
+
+	CorrelationID    bool
+	CorrelationLabel string
+
+	LogBinaryMode string
+	LogHexBytes   int
+	MinLogBytes   int
+
+	StartSampleRate float64
+
+	LogErrorPattern *regexp.Regexp `json:"-"`
+
+	HTTPAddr string
+	Store    *EventStore `json:"-"`
+
+	ColorMap map[string]string
+
+	Cache           *ContainerCache `json:"-"`
+	ExitHistorySize int
+
+	Batcher *Batcher `json:"-"`
+
+	EventReplaySkew time.Duration
+
+	HostInfo *InfoCache `json:"-"`
+
+	RegistryField     bool
+	TrustedRegistries []string
+
+	Health *HealthDebouncer `json:"-"`
+
+	DedupKey *KeyTemplate
+
+	Tracer *Tracer `json:"-"`
+
+	Errors *ErrorCounter `json:"-"`
+
+	EventDeadline time.Duration
+
+	EventTimeMaxSkew time.Duration
+
+	Churn *ChurnDetector `json:"-"`
+
+	DebugRawEvent      string
+	DebugRawEventBytes int
+
+	Schedule *Schedule `json:"-"`
 }
 
-// NewConfig is constructor
-func NewConfig() (*Config, error) {
+// NewConfig is constructor. cli is used by targets which act on containers.
+func NewConfig(cli *client.Client) (*Config, error) {
 	slackURL := os.Getenv(SlackURLEnv)
 	discordURL := os.Getenv(DiscordURLEnv)
-	if slackURL == "" && discordURL == "" {
-		return nil, fmt.Errorf("%s and/or %s must be set", SlackURLEnv, DiscordURLEnv)
+	targets, err := newTargets(cli, slackURL, discordURL)
+	if err != nil {
+		return nil, err
+	}
+	built := false
+	defer func() {
+		// A config which fails to load leaves nothing running
+		if !built {
+			closeTargets(targets, nil)
+		}
+	}()
+	switches, err := newTargetSwitch(targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one of %s must be set", strings.Join([]string{
+			SlackURLEnv, DiscordURLEnv, SlackBotTokenEnv, TeamsURLEnv, LogFileEnv, CallbackURLEnv, ESURLEnv, SMTPHostEnv,
+		}, ", "))
+	}
+	routes, err := parseRoutes(os.Getenv(RoutesEnv), targets)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseRules(os.Getenv(RulesEnv), targets)
+	if err != nil {
+		return nil, err
+	}
+	ignoreImages := DefaultIgnoreImages
+	if v, ok := os.LookupEnv(IgnoreImagesEnv); ok {
+		patterns, err := parseImagePatterns(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", IgnoreImagesEnv, err)
+		}
+		ignoreImages = patterns
+	}
+	extraFields, err := parseExtraFields(os.Getenv(ExtraFieldsEnv))
+	if err != nil {
+		return nil, err
+	}
+	maxFields, err := parseInt(MaxFieldsEnv, 0)
+	if err != nil {
+		return nil, err
+	}
+	eventsMode := os.Getenv(EventsModeEnv)
+	switch eventsMode {
+	case "":
+		eventsMode = StreamMode
+	case StreamMode, PollMode:
+	default:
+		return nil, fmt.Errorf("%s must be %s or %s", EventsModeEnv, StreamMode, PollMode)
+	}
+	pollInterval, err := parseDuration(PollIntervalEnv, DefaultPollInterval)
+	if err != nil {
+		return nil, err
+	}
+	var logMinSeverity Severity
+	if v := os.Getenv(LogMinSeverityEnv); v != "" {
+		if logMinSeverity, err = ParseSeverity(v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", LogMinSeverityEnv, err)
+		}
+	}
+	logStreams, err := parseSeverityLogStreams()
+	if err != nil {
+		return nil, err
+	}
+	successExitCodes := []int{0}
+	if v, ok := os.LookupEnv(SuccessExitCodesEnv); ok {
+		if successExitCodes, err = parseExitCodes(v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", SuccessExitCodesEnv, err)
+		}
+	}
+	correlation, err := parseBool(CorrelationIDEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	correlationLabel := os.Getenv(CorrelationLabelEnv)
+	if correlationLabel == "" {
+		correlationLabel = DefaultCorrelationLabel
+	}
+	logBinaryMode := os.Getenv(LogBinaryModeEnv)
+	switch logBinaryMode {
+	case "":
+		logBinaryMode = BinaryReplace
+	case BinaryReplace, BinaryHex:
+	default:
+		return nil, fmt.Errorf("%s must be %s or %s", LogBinaryModeEnv, BinaryReplace, BinaryHex)
+	}
+	logHexBytes, err := parseInt(LogHexBytesEnv, DefaultLogHexBytes)
+	if err != nil {
+		return nil, err
+	}
+	minLogBytes, err := parseInt(MinLogBytesEnv, 0)
+	if err != nil {
+		return nil, err
+	}
+	startSampleRate, err := parseSampleRate(StartSampleRateEnv)
+	if err != nil {
+		return nil, err
+	}
+	var logErrorPattern *regexp.Regexp
+	extract, err := parseBool(LogErrorExtractEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if extract {
+		pattern := os.Getenv(LogErrorPatternEnv)
+		if pattern == "" {
+			pattern = DefaultLogErrorPattern
+		}
+		if logErrorPattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", LogErrorPatternEnv, err)
+		}
+	}
+	httpAddr := os.Getenv(HTTPAddrEnv)
+	var store *EventStore
+	if httpAddr != "" {
+		size, err := parseInt(EventStoreSizeEnv, DefaultEventStoreSize)
+		if err != nil {
+			return nil, err
+		}
+		// 0 keeps events regardless of their age
+		var retention time.Duration
+		if os.Getenv(EventStoreRetentionEnv) != "0" {
+			if retention, err = parseDuration(EventStoreRetentionEnv, DefaultEventStoreRetention); err != nil {
+				return nil, err
+			}
+		}
+		store = NewEventStore(size, retention)
+	}
+	colorMap, err := parseColorMap(os.Getenv(ColorMapEnv))
+	if err != nil {
+		return nil, err
+	}
+	exitHistorySize, err := parseInt(ExitHistorySizeEnv, 0)
+	if err != nil {
+		return nil, err
+	}
+	eventReplaySkew, err := parseDuration(EventReplaySkewEnv, DefaultEventReplaySkew)
+	if err != nil {
+		return nil, err
+	}
+	registryField, err := parseBool(RegistryFieldEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	trustedRegistries, err := parseImagePatterns(os.Getenv(TrustedRegistriesEnv))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", TrustedRegistriesEnv, err)
+	}
+	cache := NewContainerCache(DefaultCacheSize)
+	dedupKey, err := NewKeyTemplate(os.Getenv(DedupKeyEnv))
+	if err != nil {
+		return nil, err
+	}
+	var health *HealthDebouncer
+	healthEvents, err := parseBool(HealthEventsEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if healthEvents {
+		// 0 notifies unhealthy containers right away
+		var grace time.Duration
+		if os.Getenv(HealthGraceEnv) != "0" {
+			if grace, err = parseDuration(HealthGraceEnv, DefaultHealthGrace); err != nil {
+				return nil, err
+			}
+		}
+		health = NewHealthDebouncer(grace, cache, dedupKey)
+	}
+	// 0 doesn't bound processing of events
+	var eventDeadline time.Duration
+	if os.Getenv(EventDeadlineEnv) != "0" {
+		if eventDeadline, err = parseDuration(EventDeadlineEnv, DefaultEventDeadline); err != nil {
+			return nil, err
+		}
+	}
+	scheduleLoc, err := readScheduleLocation()
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := parseSchedule(os.Getenv(ScheduleEnv), scheduleLoc)
+	if err != nil {
+		return nil, err
+	}
+	eventTimeMaxSkew, err := parseDuration(EventTimeMaxSkewEnv, DefaultEventTimeMaxSkew)
+	if err != nil {
+		return nil, err
+	}
+	debugRawEvent := os.Getenv(DebugRawEventEnv)
+	switch debugRawEvent {
+	case "", RawEventLog, RawEventAttach:
+	default:
+		return nil, fmt.Errorf("%s must be %s or %s", DebugRawEventEnv, RawEventLog, RawEventAttach)
+	}
+	debugRawEventBytes, err := parseInt(DebugRawEventBytesEnv, DefaultDebugRawEventBytes)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{
+		SlackURL:      slackURL,
+		DiscordURL:    discordURL,
+		Targets:       targets,
+		Switches:      switches,
+		Routes:        routes,
+		Rules:         rules,
+		ExtraFields:   extraFields,
+		LabelFields:   splitList(os.Getenv(LabelFieldsEnv)),
+		MaxFields:     maxFields,
+		FieldPriority: splitList(os.Getenv(FieldPriorityEnv)),
+		EventsMode:    eventsMode,
+		PollInterval:  pollInterval,
+
+		LogMinSeverity:   logMinSeverity,
+		LogStreams:       logStreams,
+		SuccessExitCodes: successExitCodes,
+
+		CorrelationID:    correlation,
+		CorrelationLabel: correlationLabel,
+
+		LogBinaryMode: logBinaryMode,
+		LogHexBytes:   logHexBytes,
+		MinLogBytes:   minLogBytes,
+
+		StartSampleRate: startSampleRate,
+
+		LogErrorPattern: logErrorPattern,
+
+		HTTPAddr: httpAddr,
+		Store:    store,
+
+		ColorMap: colorMap,
+
+		Cache:           cache,
+		ExitHistorySize: exitHistorySize,
+
+		EventReplaySkew: eventReplaySkew,
+
+		RegistryField:     registryField,
+		TrustedRegistries: trustedRegistries,
+
+		Health: health,
+
+		DedupKey: dedupKey,
+
+		Errors: NewErrorCounter(),
+
+		EventDeadline: eventDeadline,
+
+		EventTimeMaxSkew: eventTimeMaxSkew,
+
+		DebugRawEvent:      debugRawEvent,
+		DebugRawEventBytes: debugRawEventBytes,
+
+		Schedule: schedule,
+	}
+	for _, t := range targets {
+		if limited, ok := t.(*RateLimitedTarget); ok {
+			limited.allow = config.allowed
+		}
+		if bot, ok := unwrapTarget(t).(*SlackBotTarget); ok {
+			bot.allow = config.allowed
+		}
+	}
+	config.addFilter("ignore images "+strings.Join(ignoreImages, ","), IgnoreImagesFilter(ignoreImages))
+	config.addFilter("ignore build helpers", IgnoreHelpersFilter)
+	suppressSuccess, err := parseBool(SuppressSuccessEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if suppressSuccess {
+		config.addFilter("suppress success", config.SuppressSuccessFilter)
+	}
+	switch mode := os.Getenv(BatchModeEnv); mode {
+	case "":
+	case BatchSwarm:
+		window, err := parseDuration(BatchWindowEnv, DefaultBatchWindow)
+		if err != nil {
+			return nil, err
+		}
+		format := os.Getenv(BatchFormatEnv)
+		switch format {
+		case "":
+			format = BatchAttachments
+		case BatchAttachments, BatchTable:
+		default:
+			return nil, fmt.Errorf("%s must be %s or %s", BatchFormatEnv, BatchAttachments, BatchTable)
+		}
+		logBytes, err := parseInt(BatchLogBytesEnv, 0)
+		if err != nil {
+			return nil, err
+		}
+		config.Batcher = NewBatcher(window, format, logBytes, swarmServiceKey, config.deliver)
+	default:
+		return nil, fmt.Errorf("%s must be %s", BatchModeEnv, BatchSwarm)
+	}
+	oomHostInfo, err := parseBool(OOMHostInfoEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if oomHostInfo {
+		ttl, err := parseDuration(HostInfoTTLEnv, DefaultHostInfoTTL)
+		if err != nil {
+			return nil, err
+		}
+		config.HostInfo = NewInfoCache(ttl)
+	}
+	scheduleSummary, err := parseBool(ScheduleSummaryEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	churnMax, churnWindow, err := parseRateLimit(ImageChurnEnv)
+	if err != nil {
+		return nil, err
+	}
+	if churnMax > 0 {
+		config.Churn = NewChurnDetector(churnMax, churnWindow)
+	}
+	requireOptIn, err := parseBool(RequireOptInEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if requireOptIn {
+		label := os.Getenv(OptInLabelEnv)
+		if label == "" {
+			label = DefaultOptInLabel
+		}
+		config.addFilter("require opt-in "+label, OptInFilter(label))
+	}
+	// The tracer and schedule summaries start last, so a config which
+	// fails to load doesn't leave them running
+	if config.Tracer, err = newTracer(); err != nil {
+		return nil, err
 	}
-	return &Config{
-		SlackURL:   slackURL,
-		DiscordURL: discordURL,
-	}, nil
+	built = true
+	if schedule != nil && scheduleSummary {
+		go schedule.Summarize(config.deliver)
+	}
+	return config, nil
 }
 
-func main() {
+// addFilter adds f described by name
+func (c *Config) addFilter(name string, f Filter) {
+	c.Filters = append(c.Filters, f)
+	c.FilterNames = append(c.FilterNames, name)
+}
+
+// Allow reports whether all filters pass for msg
+func (c *Config) Allow(msg *events.Message) bool {
+	for _, f := range c.Filters {
+		if !f(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseDuration reads a positive duration from env key, or returns def when unset
+func parseDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 10s", key)
+	}
+	return d, nil
+}
 
+// parseInt reads a non-negative integer from env key, or returns def when unset
+func parseInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// parseBool reads a boolean from env key, or returns def when unset
+func parseBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return b, nil
+}
+
+func main() {
+	// ENV_FILE may set API_VERSION and DOCKER_HOST too
+	if err := loadEnvFile(); err != nil {
+		log.Fatal(err)
+	}
 	apiVersion := os.Getenv("API_VERSION")
 	if apiVersion == "" {
 		log.Fatal("API_VERSION must be set as your docker api version")
 	}
-	config, err := NewConfig()
+	cli, err := newClient(apiVersion)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer cli.Close()
 
-	cli, err := client.NewClientWithOpts(client.WithVersion(apiVersion))
+	config, err := NewConfig(cli)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer cli.Close()
 
+	startupNotice, err := parseBool(StartupNoticeEnv, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if startupNotice {
+		config.deliver(startupMessage(cli, config))
+	}
+
+	holder := NewConfigHolder(config)
+	reload := func() error {
+		return reloadConfig(holder, func() (*Config, error) { return NewConfig(cli) })
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reload(); err != nil {
+				log.Printf("reload: %v", err)
+			}
+		}
+	}()
+
+	if config.HTTPAddr != "" {
+		go func() {
+			log.Fatal(NewServer(holder, reload).ListenAndServe(config.HTTPAddr))
+		}()
+	}
+
+	// Spans on their way are exported before exiting
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-term
+		holder.Load().Tracer.Close()
+		os.Exit(0)
+	}()
+
+	replay := NewReplay(config.EventReplaySkew)
 	for {
-		if err := start(cli, config); err != nil {
+		if err := start(cli, holder, replay); err != nil {
 			log.Println(err)
 		}
 	}
 }
 
-func start(cli *client.Client, config *Config) (err error) {
+// start watches events until the connection to the daemon fails. Each event
+// is handled with the Config current when it arrives.
+func start(cli *client.Client, holder *ConfigHolder, replay *Replay) (err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	msgChan, errChan := cli.Events(ctx, types.EventsOptions{})
+	config := holder.Load()
+
+	var msgChan <-chan events.Message
+	var errChan <-chan error
+	if config.EventsMode == PollMode {
+		msgChan, errChan = pollEvents(ctx, cli, config.PollInterval)
+	} else {
+		msgChan, errChan = cli.Events(ctx, types.EventsOptions{Since: replay.Since()})
+	}
 
 L:
 	for {
 		select {
 		case msg := <-msgChan:
-			switch msg.Status {
-			case Start:
-				m, err := makeStartMessage(&msg)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				go m.Send(config)
-			case Die:
-
-				// Collect logs
-				reader, err := cli.ContainerLogs(ctx, msg.ID, types.ContainerLogsOptions{
-					Since:      "30s",
-					ShowStdout: true,
-					ShowStderr: true,
-				})
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				m, err := makeDieMessage(&msg, reader)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				go m.Send(config)
+			config := holder.Load()
+			// Before replay, so a future time can't make replay skip events
+			config.fixEventTime(&msg, time.Now())
+			if replay.Seen(&msg) {
+				continue
 			}
+			handle(cli, config, &msg)
+		case msg := <-holder.Load().Health.Confirmed():
+			process(cli, holder.Load(), &msg)
 		case err = <-errChan:
 			break L
 		}
@@ -119,6 +636,190 @@ L:
 	return
 }
 
+// handle filters msg before it is processed
+func handle(cli *client.Client, config *Config, msg *events.Message) {
+	config.logRawEvent(msg)
+	config.recordTrigger(msg)
+	switch msg.Status {
+	case Destroy:
+		config.Cache.Forget(msg.ID)
+		// Exit history and health are kept by DEDUP_KEY
+		if key := config.DedupKey.Key(newEvent(msg, 0)); key != msg.ID {
+			config.Cache.Forget(key)
+		}
+		return
+	case Create:
+		config.Cache.SetCreated(msg.ID, eventTime(msg))
+		return
+	}
+	if !config.Allow(msg) {
+		return
+	}
+	if !config.Health.Pass(msg) {
+		return
+	}
+	process(cli, config, msg)
+}
+
+// process builds the message of msg and sends it, within EVENT_DEADLINE
+// for both, or each when building takes up all of it. Processing isn't bound
+// to the events stream, so a reconnect doesn't cancel messages on their way.
+func process(cli *client.Client, config *Config, msg *events.Message) {
+	ctx, cancel := config.eventContext()
+	var span *Span
+	sent := false
+	defer func() {
+		if !sent {
+			span.End()
+			cancel()
+		}
+	}()
+
+	e := newEvent(msg, config.severityOf(msg))
+	churn := config.Churn.Observe(config.churnKey(e), e)
+	if len(churn) > 0 && e.Severity < Warning {
+		e.Severity = Warning
+	}
+	if !config.applyRules(e) {
+		return
+	}
+	if !config.notifiable(e) {
+		return
+	}
+	if !config.Schedule.Allow(e) {
+		return
+	}
+	if !config.sample(e) {
+		return
+	}
+	if config.CorrelationID {
+		e.CorrelationID = correlationID(msg, config.CorrelationLabel)
+	}
+	span = config.eventSpan(e)
+	ctx = withSpan(ctx, span)
+	m, err := buildMessage(ctx, cli, config, msg, e)
+	if err != nil {
+		span.SetError(err)
+		config.Errors.Inc("build")
+		log.Println(err)
+		return
+	}
+	if m == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		// Collecting context used up EVENT_DEADLINE, so give delivery of the
+		// summary its own
+		cancel()
+		ctx, cancel = config.eventContext()
+		ctx = withSpan(ctx, span)
+	}
+	config.colorize(m, e)
+	config.addExitHistory(m, e)
+	config.addRegistry(m, e)
+	config.addTrigger(ctx, cli, m, e)
+	config.addCreateToStart(m, e)
+	config.addImageChurn(m, e, churn)
+	config.addRawEvent(m, msg)
+	config.addFields(m, e)
+	config.addSampleFooter(m, e)
+	if config.Store != nil {
+		config.Store.Add(e)
+	}
+	if config.Batcher != nil && config.Batcher.Add(e, m) {
+		span.SetAttr("docker_notify.batched", true)
+		return
+	}
+	sent = true
+	go func() {
+		defer cancel()
+		defer span.End()
+		config.notify(ctx, e, m)
+	}()
+}
+
+// eventContext returns context bounding processing of an event by
+// EVENT_DEADLINE
+func (c *Config) eventContext() (context.Context, context.CancelFunc) {
+	if c.EventDeadline <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.EventDeadline)
+}
+
+// deliver sends e and m within EVENT_DEADLINE
+func (c *Config) deliver(e *Event, m *Message) {
+	ctx, cancel := c.eventContext()
+	defer cancel()
+	c.notify(ctx, e, m)
+}
+
+// notifiable reports whether events of the type of e get a message
+func (c *Config) notifiable(e *Event) bool {
+	switch e.Type {
+	case Start, Die, OOM:
+		return true
+	case Unhealthy, Healthy:
+		return c.Health != nil
+	}
+	return false
+}
+
+func buildMessage(ctx context.Context, cli *client.Client, config *Config, msg *events.Message, e *Event) (m *Message, err error) {
+	switch e.Type {
+	case Start:
+		m, err = makeStartMessage(msg)
+	case Die:
+		m, err = makeDieMessage(msg)
+	case OOM:
+		m, err = makeOOMMessage(msg)
+	case Unhealthy, Healthy:
+		if config.Health == nil {
+			return nil, nil
+		}
+		m, err = makeHealthMessage(msg, e.Type)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.Status == Die || msg.Status == OOM {
+		config.addRestartPolicy(ctx, cli, m, e)
+	}
+	if msg.Status == OOM {
+		config.addHostInfo(ctx, cli, m)
+	}
+	if !config.wantLogs(msg, e.Severity) {
+		return m, nil
+	}
+
+	// Collect logs
+	streams := config.logStreamsOf(e.Severity)
+	reader, err := cli.ContainerLogs(ctx, msg.ID, types.ContainerLogsOptions{
+		Since:      "30s",
+		ShowStdout: streams.Stdout,
+		ShowStderr: streams.Stderr,
+	})
+	if isLogsUnsupported(err) {
+		m.Attachments[0].Text = logsUnavailable(ctx, cli, msg.ID)
+		return m, nil
+	}
+	if err == nil {
+		defer reader.Close()
+		err = config.attachLogs(m, reader)
+	}
+	if err != nil && ctx.Err() != nil {
+		// Send the summary assembled so far
+		log.Printf("%s: timed out collecting logs of %s", EventDeadlineEnv, e.Name)
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func makeStartMessage(msg *events.Message) (m *Message, err error) {
 	name, ok := msg.Actor.Attributes["name"]
 	if !ok {
@@ -136,7 +837,7 @@ func makeStartMessage(msg *events.Message) (m *Message, err error) {
 	return
 }
 
-func makeDieMessage(msg *events.Message, logReder io.Reader) (m *Message, err error) {
+func makeDieMessage(msg *events.Message) (m *Message, err error) {
 	exitCode, ok := msg.Actor.Attributes["exitCode"]
 	if !ok {
 		return nil, errors.New("no exitCode")
@@ -154,15 +855,67 @@ func makeDieMessage(msg *events.Message, logReder io.Reader) (m *Message, err er
 			},
 		},
 	}
-	b, err := ioutil.ReadAll(logReder)
-	if err != nil {
-		return nil, err
+	return
+}
+
+func makeHealthMessage(msg *events.Message, status string) (m *Message, err error) {
+	name, ok := msg.Actor.Attributes["name"]
+	if !ok {
+		return nil, errors.New("no name")
+	}
+	title, color := "Container unhealthy", DieColor
+	if status == Healthy {
+		title, color = "Container healthy again", StartColor
+	}
+	m = &Message{
+		Attachments: []Attachment{
+			{
+				Title: fmt.Sprintf("%s. name => %s image => %s", title, name, msg.From),
+				Color: color,
+				TS:    msg.Time,
+			},
+		},
 	}
-	m.Attachments[0].Text = "```" + string(b) + "```"
+	return
+}
 
+func makeOOMMessage(msg *events.Message) (m *Message, err error) {
+	name, ok := msg.Actor.Attributes["name"]
+	if !ok {
+		return nil, errors.New("no name")
+	}
+	m = &Message{
+		Attachments: []Attachment{
+			{
+				Title: fmt.Sprintf("Container ran out of memory. name => %s image => %s", name, msg.From),
+				Color: DieColor,
+				TS:    msg.Time,
+			},
+		},
+	}
 	return
 }
 
+func (c *Config) attachLogs(m *Message, logReder io.Reader) error {
+	b, err := ioutil.ReadAll(logReder)
+	if err != nil {
+		return err
+	}
+	logs := c.sanitizeLogs(demux(b))
+	if len(strings.TrimSpace(logs)) < c.MinLogBytes {
+		return nil
+	}
+	m.Attachments[0].logs = logs
+	m.Attachments[0].jsonLogs = isJSONLines(logs)
+	m.Attachments[0].Text = "```" + logs + "```"
+	if c.LogErrorPattern != nil {
+		if line := firstMatch(logs, c.LogErrorPattern); line != "" {
+			m.Attachments[0].Fields = append([]Field{{Title: ErrorLineField, Value: line}}, m.Attachments[0].Fields...)
+		}
+	}
+	return nil
+}
+
 // Field is field of Attachment
 type Field struct {
 	Title string `json:"title"`
@@ -185,37 +938,24 @@ type Attachment struct {
 	FooterIcon string  `json:"footer_icon"`
 	TS         int64   `json:"ts"`
 	Fields     []Field `json:"fields"`
+
+	// logs is the raw log text wrapped into Text
+	logs string
+	// jsonLogs tells that each line of logs is JSON
+	jsonLogs bool
 }
 
 // Message is struct of Slack's webhook
 type Message struct {
 	Text        string       `json:"text"`
 	Attachments []Attachment `json:"attachments"`
-}
 
-// Send sends message to url
-func (m *Message) Send(config *Config) {
-	b, err := json.Marshal(m)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	if config.SlackURL != "" {
-		if err = m.post(config.SlackURL, b); err != nil {
-			log.Println(err)
-			return
-		}
-	}
-	if config.DiscordURL != "" {
-		if err = m.post(config.DiscordURL, b); err != nil {
-			log.Println(err)
-			return
-		}
-	}
+	// events are the events of a batched message
+	events []*Event
 }
 
-func (m *Message) post(u string, body []byte) (err error) {
-	resp, err := http.Post(u, "application/json", bytes.NewBuffer(body))
+func (m *Message) post(ctx context.Context, u string, body []byte) (err error) {
+	resp, err := postJSON(ctx, u, body)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// EventsModeEnv is key of EVENTS_MODE
+	EventsModeEnv = "EVENTS_MODE"
+	// PollIntervalEnv is key of POLL_INTERVAL
+	PollIntervalEnv = "POLL_INTERVAL"
+	// StreamMode reads events from the streaming events API
+	StreamMode = "stream"
+	// PollMode synthesizes events by diffing the container list
+	PollMode = "poll"
+	// DefaultPollInterval is interval between container list polls
+	DefaultPollInterval = 10 * time.Second
+	// UnknownExitCode is exit code of containers removed before it was read
+	UnknownExitCode = "unknown"
+)
+
+// pollEvents periodically lists containers and emits start/die events for
+// containers which started or stopped running since the previous poll.
+// It has the same contract as client.Events.
+func pollEvents(ctx context.Context, cli *client.Client, interval time.Duration) (<-chan events.Message, <-chan error) {
+	msgChan := make(chan events.Message)
+	errChan := make(chan error, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var running map[string]types.Container
+		for {
+			containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+			if err != nil {
+				errChan <- err
+				return
+			}
+			current := make(map[string]types.Container)
+			for _, c := range containers {
+				if c.State == "running" {
+					current[c.ID] = c
+				}
+			}
+			if running != nil {
+				for id, c := range current {
+					if _, ok := running[id]; !ok {
+						if !emit(ctx, msgChan, pollMessage(Start, c)) {
+							return
+						}
+					}
+				}
+				for id, c := range running {
+					if _, ok := current[id]; ok {
+						continue
+					}
+					msg := pollMessage(Die, c)
+					msg.Actor.Attributes["exitCode"] = UnknownExitCode
+					if info, err := cli.ContainerInspect(ctx, id); err == nil && info.State != nil {
+						msg.Actor.Attributes["exitCode"] = strconv.Itoa(info.State.ExitCode)
+					}
+					if !emit(ctx, msgChan, msg) {
+						return
+					}
+				}
+			}
+			running = current
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return msgChan, errChan
+}
+
+func emit(ctx context.Context, msgChan chan<- events.Message, msg events.Message) bool {
+	select {
+	case msgChan <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func pollMessage(action string, c types.Container) events.Message {
+	attributes := map[string]string{
+		"image": c.Image,
+	}
+	for k, v := range c.Labels {
+		attributes[k] = v
+	}
+	if len(c.Names) > 0 {
+		attributes["name"] = strings.TrimPrefix(c.Names[0], "/")
+	}
+	now := time.Now()
+	return events.Message{
+		Status: action,
+		ID:     c.ID,
+		From:   c.Image,
+		Type:   events.ContainerEventType,
+		Action: action,
+		Actor: events.Actor{
+			ID:         c.ID,
+			Attributes: attributes,
+		},
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TargetRateLimitEnvFormat is format of <TARGET>_RATE_LIMIT keys, e.g. SLACK_RATE_LIMIT=10/1m
+	TargetRateLimitEnvFormat = "%s_RATE_LIMIT"
+	// SummaryColor is color for summary messages
+	SummaryColor = "#ffa000"
+	// Summary is type of summary events
+	Summary = "summary"
+)
+
+// RateLimitedTarget sends at most max messages per window to a target.
+// Messages over the limit are counted per severity and summarized in one
+// message at the end of the window.
+type RateLimitedTarget struct {
+	Target
+	max    int
+	window time.Duration
+	// allow reports whether the summary may be sent, like other messages,
+	// when it is set
+	allow func(e *Event, name string) bool
+
+	mu         sync.Mutex
+	sent       int
+	suppressed map[Severity]int
+	timer      *time.Timer
+}
+
+// NewRateLimitedTarget is constructor
+func NewRateLimitedTarget(t Target, max int, window time.Duration) *RateLimitedTarget {
+	return &RateLimitedTarget{
+		Target:     t,
+		max:        max,
+		window:     window,
+		suppressed: make(map[Severity]int),
+	}
+}
+
+// Send sends m unless the limit of the current window is reached
+func (t *RateLimitedTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	t.mu.Lock()
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.window, t.flush)
+	}
+	if t.sent >= t.max {
+		t.suppressed[e.Severity]++
+		t.mu.Unlock()
+		return nil
+	}
+	t.sent++
+	t.mu.Unlock()
+	return t.Target.Send(ctx, e, m)
+}
+
+// RateLimitState is state of the current window of a RateLimitedTarget
+type RateLimitState struct {
+	Max        int              `json:"max"`
+	Window     string           `json:"window"`
+	Sent       int              `json:"sent"`
+	Suppressed map[Severity]int `json:"suppressed"`
+}
+
+// State returns state of the current window
+func (t *RateLimitedTarget) State() RateLimitState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	suppressed := make(map[Severity]int, len(t.suppressed))
+	for s, n := range t.suppressed {
+		suppressed[s] = n
+	}
+	return RateLimitState{
+		Max:        t.max,
+		Window:     t.window.String(),
+		Sent:       t.sent,
+		Suppressed: suppressed,
+	}
+}
+
+// Close ends the current window and sends its summary right away
+func (t *RateLimitedTarget) Close() {
+	t.mu.Lock()
+	stopped := t.timer != nil && t.timer.Stop()
+	t.mu.Unlock()
+	if stopped {
+		t.flush()
+	}
+}
+
+// flush ends the window and sends the summary of suppressed messages
+func (t *RateLimitedTarget) flush() {
+	t.mu.Lock()
+	suppressed := t.suppressed
+	t.suppressed = make(map[Severity]int)
+	t.sent = 0
+	t.timer = nil
+	t.mu.Unlock()
+
+	if len(suppressed) == 0 {
+		return
+	}
+	e, m := summaryMessage(suppressed, t.window)
+	if t.allow != nil && !t.allow(e, t.Name()) {
+		return
+	}
+	if err := t.Target.Send(context.Background(), e, m); err != nil {
+		log.Printf("%s: %v", t.Name(), err)
+	}
+}
+
+// summaryMessage tells how many events of each severity were suppressed
+func summaryMessage(suppressed map[Severity]int, window time.Duration) (*Event, *Message) {
+	var severities []Severity
+	total := 0
+	for s, n := range suppressed {
+		severities = append(severities, s)
+		total += n
+	}
+	sort.Slice(severities, func(i, j int) bool { return severities[i] > severities[j] })
+	counts := make([]string, len(severities))
+	for i, s := range severities {
+		counts[i] = fmt.Sprintf("%s: %d", s, suppressed[s])
+	}
+	now := time.Now()
+	e := &Event{
+		Time:     now,
+		Type:     Summary,
+		Severity: severities[0],
+	}
+	m := &Message{
+		Attachments: []Attachment{
+			{
+				Title: fmt.Sprintf("%d additional events suppressed in the last %s", total, window),
+				Text:  strings.Join(counts, ", "),
+				Color: SummaryColor,
+				TS:    now.Unix(),
+			},
+		},
+	}
+	return e, m
+}
+
+// parseRateLimit parses a limit like "10/1m"
+func parseRateLimit(key string) (max int, window time.Duration, err error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, 0, nil
+	}
+	i := strings.Index(v, "/")
+	if i > 0 {
+		max, err = strconv.Atoi(v[:i])
+		if err == nil {
+			window, err = time.ParseDuration(v[i+1:])
+		}
+	}
+	if i <= 0 || err != nil || max <= 0 || window <= 0 {
+		return 0, 0, fmt.Errorf("%s must be like 10/1m", key)
+	}
+	return max, window, nil
+}
+
+// limitTargets wraps targets which have <TARGET>_RATE_LIMIT set
+func limitTargets(targets []Target) ([]Target, error) {
+	for i, t := range targets {
+		max, window, err := parseRateLimit(fmt.Sprintf(TargetRateLimitEnvFormat, strings.ToUpper(t.Name())))
+		if err != nil {
+			return nil, err
+		}
+		if max > 0 {
+			targets[i] = NewRateLimitedTarget(t, max, window)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTarget records the events sent to it
+type recordingTarget struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (t *recordingTarget) Name() string {
+	return "recording"
+}
+
+func (t *recordingTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, e)
+	return nil
+}
+
+func (t *recordingTarget) sent() []*Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Event(nil), t.events...)
+}
+
+func TestRateLimitedTargetWindow(t *testing.T) {
+	inner := &recordingTarget{}
+	limited := NewRateLimitedTarget(inner, 2, time.Hour)
+	defer limited.Close()
+	severities := []Severity{Info, Critical, Info, Critical, Warning}
+	for _, s := range severities {
+		if err := limited.Send(context.Background(), &Event{Type: Die, Severity: s}, &Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(inner.sent()); got != 2 {
+		t.Errorf("sent %d messages, want 2", got)
+	}
+	state := limited.State()
+	if state.Sent != 2 || state.Suppressed[Info] != 1 || state.Suppressed[Critical] != 1 || state.Suppressed[Warning] != 1 {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestRateLimitedTargetSummary(t *testing.T) {
+	inner := &recordingTarget{}
+	limited := NewRateLimitedTarget(inner, 1, time.Hour)
+	for _, s := range []Severity{Info, Warning, Critical, Warning} {
+		limited.Send(context.Background(), &Event{Type: Die, Severity: s}, &Message{})
+	}
+	limited.Close()
+	sent := inner.sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want the first one and a summary", len(sent))
+	}
+	if summary := sent[1]; summary.Type != Summary || summary.Severity != Critical {
+		t.Errorf("summary = %+v, want a critical summary", summary)
+	}
+	// The next window starts empty
+	limited.Send(context.Background(), &Event{Type: Die, Severity: Info}, &Message{})
+	defer limited.Close()
+	if got := len(inner.sent()); got != 3 {
+		t.Errorf("sent %d messages, want 3", got)
+	}
+	if state := limited.State(); len(state.Suppressed) != 0 {
+		t.Errorf("suppressed = %v, want none", state.Suppressed)
+	}
+}
+
+func TestRateLimitedTargetSummaryAfterWindow(t *testing.T) {
+	inner := &recordingTarget{}
+	limited := NewRateLimitedTarget(inner, 1, 10*time.Millisecond)
+	limited.Send(context.Background(), &Event{Type: Die, Severity: Info}, &Message{})
+	limited.Send(context.Background(), &Event{Type: Die, Severity: Info}, &Message{})
+	deadline := time.Now().Add(time.Second)
+	for len(inner.sent()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("no summary after the window")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRateLimitedTargetSummaryNotAllowed(t *testing.T) {
+	inner := &recordingTarget{}
+	limited := NewRateLimitedTarget(inner, 1, time.Hour)
+	limited.allow = func(e *Event, name string) bool { return e.Type != Summary }
+	limited.Send(context.Background(), &Event{Type: Die, Severity: Info}, &Message{})
+	limited.Send(context.Background(), &Event{Type: Die, Severity: Info}, &Message{})
+	limited.Close()
+	if got := len(inner.sent()); got != 1 {
+		t.Errorf("sent %d messages, want no summary", got)
+	}
+}
+
+func TestConfigAllowedSummary(t *testing.T) {
+	inner := &recordingTarget{}
+	switches, err := newTargetSwitch([]Target{inner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Switches: switches}
+	summary := &Event{Type: Summary, Severity: Warning}
+	if !config.allowed(summary, inner.Name()) {
+		t.Error("summary not allowed to an enabled target")
+	}
+	switches.Set(inner.Name(), false)
+	if config.allowed(summary, inner.Name()) {
+		t.Error("summary allowed to a muted target")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// DebugRawEventEnv is key of DEBUG_RAW_EVENT
+	DebugRawEventEnv = "DEBUG_RAW_EVENT"
+	// DebugRawEventBytesEnv is key of DEBUG_RAW_EVENT_BYTES
+	DebugRawEventBytesEnv = "DEBUG_RAW_EVENT_BYTES"
+	// RawEventLog logs raw events
+	RawEventLog = "log"
+	// RawEventAttach attaches raw events to messages
+	RawEventAttach = "attach"
+	// DefaultDebugRawEventBytes fits into a Discord field with the code block
+	DefaultDebugRawEventBytes = 1000
+	// RawEventField is title of the field of the raw event
+	RawEventField = "raw event"
+)
+
+// rawEvent is msg as JSON, truncated to DEBUG_RAW_EVENT_BYTES unless it
+// is 0
+func (c *Config) rawEvent(msg *events.Message) string {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err.Error()
+	}
+	if c.DebugRawEventBytes == 0 {
+		return string(b)
+	}
+	return truncate(string(b), c.DebugRawEventBytes)
+}
+
+// logRawEvent logs msg as Docker sent it in log mode of DEBUG_RAW_EVENT
+func (c *Config) logRawEvent(msg *events.Message) {
+	if c.DebugRawEvent == RawEventLog {
+		log.Printf("raw event: %s", c.rawEvent(msg))
+	}
+}
+
+// addRawEvent attaches msg as Docker sent it in attach mode of
+// DEBUG_RAW_EVENT
+func (c *Config) addRawEvent(m *Message, msg *events.Message) {
+	if c.DebugRawEvent != RawEventAttach {
+		return
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: RawEventField,
+		Value: "```" + c.rawEvent(msg) + "```",
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	// RegistryFieldEnv is key of REGISTRY_FIELD
+	RegistryFieldEnv = "REGISTRY_FIELD"
+	// TrustedRegistriesEnv is key of TRUSTED_REGISTRIES
+	TrustedRegistriesEnv = "TRUSTED_REGISTRIES"
+	// RegistryField is title of the registry field
+	RegistryField = "registry"
+	// DefaultRegistry is registry of images without a registry host
+	DefaultRegistry = "docker.io"
+)
+
+// registryOf returns the registry host of image reference, e.g. ghcr.io for
+// ghcr.io/org/app:1 and docker.io for nginx. Image IDs have no registry.
+func registryOf(image string) string {
+	if image == "" || strings.HasPrefix(image, "sha256:") {
+		return ""
+	}
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return DefaultRegistry
+	}
+	host := image[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return DefaultRegistry
+}
+
+// isTrustedRegistry reports whether the registry of image matches
+// TRUSTED_REGISTRIES. All registries are trusted when it's not set.
+func (c *Config) isTrustedRegistry(image string) bool {
+	registry := registryOf(image)
+	if len(c.TrustedRegistries) == 0 || registry == "" {
+		return true
+	}
+	for _, p := range c.TrustedRegistries {
+		if ok, _ := path.Match(p, registry); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addRegistry shows the registry of the image, flagging untrusted ones
+func (c *Config) addRegistry(m *Message, e *Event) {
+	registry := registryOf(e.Image)
+	if !c.RegistryField || registry == "" {
+		return
+	}
+	if !c.isTrustedRegistry(e.Image) {
+		registry += " (untrusted)"
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: RegistryField,
+		Value: registry,
+		Short: true,
+	})
+}
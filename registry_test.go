@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestRegistryOf(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: DefaultRegistry},
+		{image: "nginx:1.21", want: DefaultRegistry},
+		{image: "library/nginx", want: DefaultRegistry},
+		{image: "docker.io/library/nginx", want: "docker.io"},
+		{image: "ghcr.io/org/app:1", want: "ghcr.io"},
+		{image: "registry.example.com:5000/app", want: "registry.example.com:5000"},
+		{image: "localhost/app", want: "localhost"},
+		{image: "localhost:5000/app@sha256:abc", want: "localhost:5000"},
+		{image: "sha256:0123456789abcdef", want: ""},
+		{image: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := registryOf(tt.image); got != tt.want {
+			t.Errorf("registryOf(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestIsTrustedRegistry(t *testing.T) {
+	config := &Config{TrustedRegistries: []string{"docker.io", "*.example.com"}}
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{image: "nginx", want: true},
+		{image: "registry.example.com/app", want: true},
+		{image: "ghcr.io/org/app", want: false},
+		{image: "sha256:0123456789abcdef", want: true},
+	}
+	for _, tt := range tests {
+		if got := config.isTrustedRegistry(tt.image); got != tt.want {
+			t.Errorf("isTrustedRegistry(%q) = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+	if !(&Config{}).isTrustedRegistry("ghcr.io/org/app") {
+		t.Error("registry untrusted without TRUSTED_REGISTRIES")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// EnvFileEnv is key of ENV_FILE
+	EnvFileEnv = "ENV_FILE"
+)
+
+// ConfigHolder holds the current Config. Readers Load it once per event or
+// request and keep using that Config, which is never modified, while Reload
+// swaps in a new one.
+type ConfigHolder struct {
+	v  atomic.Value
+	mu sync.Mutex
+}
+
+// NewConfigHolder is constructor
+func NewConfigHolder(config *Config) *ConfigHolder {
+	h := &ConfigHolder{}
+	h.v.Store(config)
+	return h
+}
+
+// Load returns the current Config
+func (h *ConfigHolder) Load() *Config {
+	return h.v.Load().(*Config)
+}
+
+// Reload replaces the current Config by one from build. State which outlives
+// a Config, like cached containers, stored events and error counts, is kept.
+// The old Config is closed once events on their way had time to be sent.
+func (h *ConfigHolder) Reload(build func() (*Config, error)) (*Config, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	config, err := build()
+	if err != nil {
+		return nil, err
+	}
+	old := h.Load()
+	config.inherit(old)
+	h.v.Store(config)
+	deadline := old.EventDeadline
+	if deadline <= 0 {
+		deadline = DefaultEventDeadline
+	}
+	time.AfterFunc(deadline, func() { old.close(config) })
+	return config, nil
+}
+
+// inherit takes over the state of old
+func (c *Config) inherit(old *Config) {
+	c.Cache = old.Cache
+	c.Errors = old.Errors
+	if c.Churn != nil && old.Churn != nil {
+		c.Churn.inherit(old.Churn)
+	}
+	if c.Store != nil && old.Store != nil {
+		c.Store = old.Store
+	}
+	if c.Health != nil {
+		c.Health.cache = c.Cache
+		if old.Health != nil {
+			// Unhealthy containers confirmed after the reload still arrive
+			c.Health.confirmed = old.Health.confirmed
+		}
+	}
+	// Targets toggled at runtime stay so
+	for name, enabled := range old.Switches.States() {
+		c.Switches.Set(name, enabled)
+	}
+}
+
+// close stops the background work of c, handing over what is pending to
+// next. Pending batches and summaries of rate limits are sent before the
+// targets are closed.
+func (c *Config) close(next *Config) {
+	if c.Batcher != nil {
+		c.Batcher.Close()
+	}
+	if c.Schedule != nil {
+		c.Schedule.Close(next.Schedule)
+	}
+	c.Tracer.Close()
+	if c.Health != nil {
+		c.Health.Close()
+	}
+	closeTargets(c.Targets, next.Targets)
+}
+
+// closeTargets stops the background work of targets and releases their
+// files, handing over what is pending to the same targets of next
+func closeTargets(targets, next []Target) {
+	for _, t := range targets {
+		if limited, ok := t.(*RateLimitedTarget); ok {
+			limited.Close()
+		}
+		switch t := unwrapTarget(t).(type) {
+		case *SlackBotTarget:
+			bot, _ := unwrapTarget(findTarget(next, t.Name())).(*SlackBotTarget)
+			t.Close(bot)
+		case *SMTPTarget:
+			smtp, _ := unwrapTarget(findTarget(next, t.Name())).(*SMTPTarget)
+			t.Close(smtp)
+		case interface{ Close() }:
+			t.Close()
+		}
+	}
+}
+
+// unwrapTarget strips rate limiting and latency measuring off t
+func unwrapTarget(t Target) Target {
+	for {
+		switch wrapped := t.(type) {
+		case *RateLimitedTarget:
+			t = wrapped.Target
+		case *LatencyTarget:
+			t = wrapped.Target
+		default:
+			return t
+		}
+	}
+}
+
+// loadEnvFile sets the variables of ENV_FILE, lines of KEY=VALUE like
+// docker-notify.env.example, so a reload picks up their changes
+func loadEnvFile() error {
+	path := os.Getenv(EnvFileEnv)
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", EnvFileEnv, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return fmt.Errorf("%s: line %d must be KEY=VALUE", EnvFileEnv, n)
+		}
+		if err := os.Setenv(strings.TrimSpace(line[:i]), line[i+1:]); err != nil {
+			return fmt.Errorf("%s: line %d: %v", EnvFileEnv, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// reloadConfig rereads ENV_FILE and the environment into holder
+func reloadConfig(holder *ConfigHolder, build func() (*Config, error)) error {
+	if err := loadEnvFile(); err != nil {
+		return err
+	}
+	if _, err := holder.Reload(build); err != nil {
+		return err
+	}
+	log.Println("config reloaded")
+	return nil
+}
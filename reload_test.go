@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// countingTarget counts the messages sent to it
+type countingTarget struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (t *countingTarget) Name() string {
+	return "counting"
+}
+
+func (t *countingTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent++
+	return nil
+}
+
+func newTestConfig(t *testing.T, target Target) *Config {
+	t.Helper()
+	switches, err := newTargetSwitch([]Target{target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Targets:  []Target{target},
+		Switches: switches,
+		Cache:    NewContainerCache(DefaultCacheSize),
+		Store:    NewEventStore(DefaultEventStoreSize, 0),
+		Errors:   NewErrorCounter(),
+	}
+	config.addFilter("ignore build helpers", IgnoreHelpersFilter)
+	return config
+}
+
+func TestConfigHolderReload(t *testing.T) {
+	target := &countingTarget{}
+	holder := NewConfigHolder(newTestConfig(t, target))
+	first := holder.Load()
+
+	const readers, reads, reloads = 8, 100, 50
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := &events.Message{Status: Die, ID: "abc", Actor: events.Actor{Attributes: map[string]string{"name": "app"}}}
+			for j := 0; j < reads; j++ {
+				config := holder.Load()
+				if !config.Allow(msg) {
+					t.Error("Allow() = false")
+					return
+				}
+				e := newEvent(msg, Critical)
+				config.Store.Add(e)
+				config.Cache.RecordExit(msg.ID, "1", 5)
+				config.notify(context.Background(), e, &Message{Attachments: []Attachment{{}}})
+				config.Switches.Set(target.Name(), true)
+				config.debugState()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < reloads; i++ {
+			if _, err := holder.Reload(func() (*Config, error) { return newTestConfig(t, target), nil }); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if target.sent != readers*reads {
+		t.Errorf("sent %d messages, want %d", target.sent, readers*reads)
+	}
+	last := holder.Load()
+	if last == first {
+		t.Fatal("config wasn't replaced")
+	}
+	if last.Store != first.Store || last.Cache != first.Cache || last.Errors != first.Errors {
+		t.Error("reload didn't keep the state of the first config")
+	}
+	if n := len(last.Store.Query(EventQuery{})); n != readers*reads {
+		t.Errorf("stored %d events, want %d", n, readers*reads)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// EventReplaySkewEnv is key of EVENT_REPLAY_SKEW
+	EventReplaySkewEnv = "EVENT_REPLAY_SKEW"
+	// DefaultEventReplaySkew is tolerance for clock skew to the daemon
+	DefaultEventReplaySkew = 5 * time.Second
+)
+
+// Replay remembers the last seen event, so events missed while
+// reconnecting are replayed. Replaying starts skew before the last event
+// and events seen twice in the overlap are dropped.
+type Replay struct {
+	skew time.Duration
+	last time.Time
+	seen map[string]time.Time
+}
+
+// NewReplay is constructor
+func NewReplay(skew time.Duration) *Replay {
+	return &Replay{
+		skew: skew,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Since returns the Since option of the events API, or "" before the first
+// event
+func (r *Replay) Since() string {
+	if r.last.IsZero() {
+		return ""
+	}
+	since := r.last.Add(-r.skew)
+	return fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())
+}
+
+// Seen records msg and reports whether it was already seen
+func (r *Replay) Seen(msg *events.Message) bool {
+	t := time.Unix(0, msg.TimeNano)
+	key := fmt.Sprintf("%d/%s/%s/%s", msg.TimeNano, msg.Type, msg.Actor.ID, msg.Action)
+	if _, ok := r.seen[key]; ok {
+		return true
+	}
+	r.seen[key] = t
+	if t.After(r.last) {
+		r.last = t
+	}
+	for k, seen := range r.seen {
+		if r.last.Sub(seen) > 2*r.skew {
+			delete(r.seen, k)
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func replayMessage(t time.Time, id, action string) *events.Message {
+	return &events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id},
+		Time:     t.Unix(),
+		TimeNano: t.UnixNano(),
+	}
+}
+
+func TestReplaySeen(t *testing.T) {
+	r := NewReplay(5 * time.Second)
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	if r.Since() != "" {
+		t.Errorf("Since() = %q before any event", r.Since())
+	}
+	die := replayMessage(now, "abc", Die)
+	if r.Seen(die) {
+		t.Error("first event seen")
+	}
+	if !r.Seen(replayMessage(now, "abc", Die)) {
+		t.Error("replayed event not seen")
+	}
+	if r.Seen(replayMessage(now, "abc", Start)) {
+		t.Error("other action of the same time seen")
+	}
+	if r.Seen(replayMessage(now, "def", Die)) {
+		t.Error("other container seen")
+	}
+	if r.Seen(replayMessage(now.Add(time.Nanosecond), "abc", Die)) {
+		t.Error("later event seen")
+	}
+	if want := fmt.Sprintf("%d.000000001", now.Add(-5*time.Second).Unix()); r.Since() != want {
+		t.Errorf("Since() = %q, want %q", r.Since(), want)
+	}
+}
+
+func TestReplayForgets(t *testing.T) {
+	r := NewReplay(5 * time.Second)
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	r.Seen(replayMessage(now, "abc", Die))
+	r.Seen(replayMessage(now.Add(11*time.Second), "def", Die))
+	if len(r.seen) != 1 {
+		t.Errorf("%d events remembered, want those within twice the skew", len(r.seen))
+	}
+	// An older event isn't replayed anymore, so it is new
+	if r.Seen(replayMessage(now, "abc", Die)) {
+		t.Error("forgotten event seen")
+	}
+	if want := fmt.Sprintf("%d.000000000", now.Add(6*time.Second).Unix()); r.Since() != want {
+		t.Errorf("Since() = %q, want %q, not going back for older events", r.Since(), want)
+	}
+}
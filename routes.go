@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// RoutesEnv is key of ROUTES
+	RoutesEnv = "ROUTES"
+)
+
+// Routes maps severities and event types to names of targets they are sent
+// to. Event types take precedence over severities, events matching neither
+// are sent to all targets.
+type Routes map[string][]string
+
+// parseRoutes parses ROUTES, e.g. {"critical":["slack","teams"],"info":["logfile"]}
+func parseRoutes(s string, targets []Target) (Routes, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var routes Routes
+	if err := json.Unmarshal([]byte(s), &routes); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RoutesEnv, err)
+	}
+	for key, names := range routes {
+		if _, err := ParseSeverity(key); err != nil && !isEventType(key) {
+			return nil, fmt.Errorf("invalid %s: %q is neither a severity nor an event type", RoutesEnv, key)
+		}
+		for _, name := range names {
+			if findTarget(targets, name) == nil {
+				return nil, fmt.Errorf("invalid %s: unknown target %q", RoutesEnv, name)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// Routed reports whether e is sent to target name
+func (r Routes) Routed(e *Event, name string) bool {
+	names, ok := r[e.Type]
+	if !ok {
+		if names, ok = r[e.Severity.String()]; !ok {
+			return true
+		}
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
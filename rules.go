@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+const (
+	// RulesEnv is key of RULES
+	RulesEnv = "RULES"
+	// RuleNotify sends events matching a rule
+	RuleNotify = "notify"
+	// RuleDrop drops events matching a rule
+	RuleDrop = "drop"
+)
+
+// Rule decides what happens to events matching an expression
+type Rule struct {
+	When     string   `json:"when"`
+	Action   string   `json:"action"`
+	Severity string   `json:"severity,omitempty"`
+	Targets  []string `json:"targets,omitempty"`
+
+	program  *vm.Program
+	severity Severity
+}
+
+// parseRules parses RULES, e.g.
+// [{"when":"event.type == \"die\" && \"prod\" in labels","severity":"critical","targets":["slack"]}]
+func parseRules(s string, targets []Target) ([]*Rule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var rules []*Rule
+	if err := json.Unmarshal([]byte(s), &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RulesEnv, err)
+	}
+	for i, r := range rules {
+		program, err := expr.Compile(r.When, expr.Env(ruleEnv(&Event{})), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: rule %d: %v", RulesEnv, i, err)
+		}
+		r.program = program
+		switch r.Action {
+		case "":
+			r.Action = RuleNotify
+		case RuleNotify, RuleDrop:
+		default:
+			return nil, fmt.Errorf("invalid %s: rule %d: action must be %s or %s", RulesEnv, i, RuleNotify, RuleDrop)
+		}
+		if r.Severity != "" {
+			if r.severity, err = ParseSeverity(r.Severity); err != nil {
+				return nil, fmt.Errorf("invalid %s: rule %d: %v", RulesEnv, i, err)
+			}
+		}
+		for _, name := range r.Targets {
+			if findTarget(targets, name) == nil {
+				return nil, fmt.Errorf("invalid %s: rule %d: unknown target %q", RulesEnv, i, name)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// ruleEnv exposes e to rule expressions as event and labels
+func ruleEnv(e *Event) map[string]interface{} {
+	exitCode, _ := strconv.Atoi(e.ExitCode)
+	labels := e.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return map[string]interface{}{
+		"event": map[string]interface{}{
+			"type":     e.Type,
+			"id":       e.ID,
+			"name":     e.Name,
+			"image":    e.Image,
+			"exitCode": exitCode,
+			"severity": e.Severity.String(),
+		},
+		"labels": labels,
+	}
+}
+
+// applyRules runs the first rule matching e, which may change its severity
+// and targets. It reports whether e is notified; events matching no rule
+// are.
+func (c *Config) applyRules(e *Event) bool {
+	if len(c.Rules) == 0 {
+		return true
+	}
+	env := ruleEnv(e)
+	for _, r := range c.Rules {
+		matched, err := expr.Run(r.program, env)
+		if err != nil {
+			c.Errors.Inc("rules")
+			continue
+		}
+		if matched != true {
+			continue
+		}
+		if r.Action == RuleDrop {
+			return false
+		}
+		if r.severity != 0 {
+			e.Severity = r.severity
+		}
+		e.targets = r.Targets
+		return true
+	}
+	return true
+}
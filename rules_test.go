@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	targets := []Target{&recordingTarget{}}
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "unset", in: ""},
+		{name: "notify", in: `[{"when":"event.type == \"die\" && event.exitCode != 0","severity":"critical","targets":["recording"]}]`},
+		{name: "drop", in: `[{"when":"labels.env == \"dev\"","action":"drop"}]`},
+		{name: "not JSON", in: `{"when":"true"}`, wantErr: true},
+		{name: "syntax error", in: `[{"when":"event.type =="}]`, wantErr: true},
+		{name: "not a boolean", in: `[{"when":"event.name"}]`, wantErr: true},
+		{name: "unknown variable", in: `[{"when":"container.name == \"x\""}]`, wantErr: true},
+		{name: "unknown action", in: `[{"when":"true","action":"page"}]`, wantErr: true},
+		{name: "unknown severity", in: `[{"when":"true","severity":"fatal"}]`, wantErr: true},
+		{name: "unknown target", in: `[{"when":"true","targets":["pager"]}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRules(tt.in, targets)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	targets := []Target{&recordingTarget{}}
+	rules, err := parseRules(`[
+		{"when": "labels.env == \"dev\"", "action": "drop"},
+		{"when": "event.type == \"die\" && event.exitCode == 137", "severity": "info"},
+		{"when": "event.type == \"die\" && \"prod\" in labels", "severity": "critical", "targets": ["recording"]}
+	]`, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Rules: rules, Errors: NewErrorCounter()}
+	tests := []struct {
+		name         string
+		e            *Event
+		want         bool
+		wantSeverity Severity
+		wantTargets  []string
+	}{
+		{name: "dropped", e: &Event{Type: Die, Severity: Critical, Labels: map[string]string{"env": "dev"}}, want: false, wantSeverity: Critical},
+		{name: "severity", e: &Event{Type: Die, ExitCode: "137", Severity: Warning}, want: true, wantSeverity: Info},
+		{name: "targets", e: &Event{Type: Die, ExitCode: "1", Severity: Warning, Labels: map[string]string{"prod": ""}}, want: true, wantSeverity: Critical, wantTargets: []string{"recording"}},
+		{name: "first match wins", e: &Event{Type: Die, ExitCode: "137", Severity: Warning, Labels: map[string]string{"prod": ""}}, want: true, wantSeverity: Info},
+		{name: "no match", e: &Event{Type: Start, Severity: Info}, want: true, wantSeverity: Info},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.applyRules(tt.e); got != tt.want {
+				t.Errorf("applyRules() = %v, want %v", got, tt.want)
+			}
+			if tt.e.Severity != tt.wantSeverity {
+				t.Errorf("severity = %v, want %v", tt.e.Severity, tt.wantSeverity)
+			}
+			if !reflect.DeepEqual(tt.e.targets, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", tt.e.targets, tt.wantTargets)
+			}
+		})
+	}
+}
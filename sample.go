@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// StartSampleRateEnv is key of START_SAMPLE_RATE
+	StartSampleRateEnv = "START_SAMPLE_RATE"
+)
+
+// sampler is only used from the event loop
+var sampler = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// sampled reports whether e is subject to START_SAMPLE_RATE. Only start
+// events of info severity are sampled.
+func (c *Config) sampled(e *Event) bool {
+	return c.StartSampleRate < 1 && e.Severity == Info && e.Type == Start
+}
+
+// sample reports whether e should be notified
+func (c *Config) sample(e *Event) bool {
+	return !c.sampled(e) || sampler.Float64() < c.StartSampleRate
+}
+
+// addSampleFooter tells readers that m is one of a sampled subset
+func (c *Config) addSampleFooter(m *Message, e *Event) {
+	if !c.sampled(e) {
+		return
+	}
+	for i := range m.Attachments {
+		m.Attachments[i].Footer = fmt.Sprintf("sampled: %g%% of %s events are notified", c.StartSampleRate*100, e.Type)
+	}
+}
+
+func parseSampleRate(key string) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 1, nil
+	}
+	r, err := strconv.ParseFloat(v, 64)
+	if err != nil || r <= 0 || r > 1 {
+		return 0, fmt.Errorf("%s must be a number in (0, 1]", key)
+	}
+	return r, nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	// Schedules work in images without zoneinfo
+	_ "time/tzdata"
+)
+
+const (
+	// ScheduleEnv is key of SCHEDULE
+	ScheduleEnv = "SCHEDULE"
+	// ScheduleTZEnv is key of SCHEDULE_TZ
+	ScheduleTZEnv = "SCHEDULE_TZ"
+	// ScheduleSummaryEnv is key of SCHEDULE_SUMMARY
+	ScheduleSummaryEnv = "SCHEDULE_SUMMARY"
+	// scheduleCheckInterval is how often suppressed events are summarized
+	scheduleCheckInterval = time.Minute
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeWindow is a time of day range on some weekdays. A range ending before
+// it starts spans midnight.
+type TimeWindow struct {
+	days       [7]bool
+	start, end int // minutes of the day
+}
+
+// parseTimeWindow parses a window like "Mon-Fri 09:00-18:00", "Sat,Sun" or
+// "22:00-06:00"
+func parseTimeWindow(s string) (w TimeWindow, err error) {
+	days, clock := "", strings.TrimSpace(s)
+	if i := strings.Index(clock, " "); i >= 0 {
+		days, clock = clock[:i], strings.TrimSpace(clock[i+1:])
+	} else if !strings.Contains(clock, ":") {
+		days, clock = clock, ""
+	}
+	if days == "" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	for _, d := range splitList(days) {
+		from, to := d, d
+		if i := strings.Index(d, "-"); i >= 0 {
+			from, to = d[:i], d[i+1:]
+		}
+		f, ok1 := weekdays[strings.ToLower(from)]
+		t, ok2 := weekdays[strings.ToLower(to)]
+		if !ok1 || !ok2 {
+			return w, fmt.Errorf("invalid days %q", d)
+		}
+		for i := f; ; i = (i + 1) % 7 {
+			w.days[i] = true
+			if i == t {
+				break
+			}
+		}
+	}
+	if clock == "" {
+		w.start, w.end = 0, 24*60
+		return w, nil
+	}
+	i := strings.Index(clock, "-")
+	if i < 0 {
+		return w, fmt.Errorf("invalid time range %q", clock)
+	}
+	if w.start, err = parseClock(clock[:i]); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(clock[i+1:]); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t is within w. Times after midnight of a window
+// spanning midnight belong to the day it started.
+func (w TimeWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return w.days[t.Weekday()] && w.start <= minute && minute < w.end
+	}
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minute < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+// Schedule suppresses events of some event types or severities outside of
+// their time windows. Event types take precedence over severities, events
+// matching neither are always notified.
+type Schedule struct {
+	windows map[string][]TimeWindow
+	loc     *time.Location
+
+	mu         sync.Mutex
+	suppressed map[string]int
+	done       chan struct{}
+	// stopped is closed when Summarize returns, it is nil when s isn't
+	// summarized
+	stopped chan struct{}
+}
+
+// parseSchedule parses SCHEDULE, e.g. {"start":["Mon-Fri 09:00-18:00"]}
+func parseSchedule(s string, loc *time.Location) (*Schedule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ScheduleEnv, err)
+	}
+	schedule := &Schedule{
+		windows:    make(map[string][]TimeWindow, len(raw)),
+		loc:        loc,
+		suppressed: make(map[string]int),
+		done:       make(chan struct{}),
+	}
+	for key, windows := range raw {
+		if _, err := ParseSeverity(key); err != nil && !isEventType(key) {
+			return nil, fmt.Errorf("invalid %s: %q is neither a severity nor an event type", ScheduleEnv, key)
+		}
+		ws := make([]TimeWindow, len(windows))
+		for i, v := range windows {
+			w, err := parseTimeWindow(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", ScheduleEnv, err)
+			}
+			ws[i] = w
+		}
+		schedule.windows[key] = ws
+	}
+	return schedule, nil
+}
+
+// Allow reports whether e is within its schedule and counts it otherwise.
+// Allow is nil-safe.
+func (s *Schedule) Allow(e *Event) bool {
+	if s == nil {
+		return true
+	}
+	key := e.Type
+	if _, ok := s.windows[key]; !ok {
+		key = e.Severity.String()
+		if _, ok := s.windows[key]; !ok {
+			return true
+		}
+	}
+	if s.open(key, time.Now()) {
+		return true
+	}
+	s.mu.Lock()
+	s.suppressed[key]++
+	s.mu.Unlock()
+	return false
+}
+
+func (s *Schedule) open(key string, t time.Time) bool {
+	t = t.In(s.loc)
+	for _, w := range s.windows[key] {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize sends a summary of events suppressed per event type or severity
+// once its schedule opens again, until s is closed. Then the events left,
+// which no schedule suppresses anymore, are summarized right away.
+func (s *Schedule) Summarize(send func(e *Event, m *Message)) {
+	stopped := make(chan struct{})
+	defer close(stopped)
+	s.mu.Lock()
+	s.stopped = stopped
+	s.mu.Unlock()
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.summarize(send, now, func(key string) bool { return s.open(key, now) })
+		case <-s.done:
+			s.summarize(send, time.Now(), func(string) bool { return true })
+			return
+		}
+	}
+}
+
+// summarize sends a summary of the events suppressed per key for which due
+// returns true, and forgets them
+func (s *Schedule) summarize(send func(e *Event, m *Message), now time.Time, due func(key string) bool) {
+	s.mu.Lock()
+	var counts []string
+	for key, n := range s.suppressed {
+		if due(key) {
+			counts = append(counts, fmt.Sprintf("%s: %d", key, n))
+			delete(s.suppressed, key)
+		}
+	}
+	s.mu.Unlock()
+	if len(counts) == 0 {
+		return
+	}
+	sort.Strings(counts)
+	send(&Event{Time: now, Type: Summary, Severity: Info}, &Message{
+		Attachments: []Attachment{
+			{
+				Title: "events suppressed outside of their schedule",
+				Text:  strings.Join(counts, ", "),
+				Color: SummaryColor,
+				TS:    now.Unix(),
+			},
+		},
+	})
+}
+
+// Close stops summarizing. Events suppressed so far are moved to next, the
+// schedule replacing s, when next has windows for them. The others are
+// summarized before Close returns.
+func (s *Schedule) Close(next *Schedule) {
+	s.mu.Lock()
+	if next != nil {
+		next.mu.Lock()
+		for key, n := range s.suppressed {
+			if _, ok := next.windows[key]; ok {
+				next.suppressed[key] += n
+				delete(s.suppressed, key)
+			}
+		}
+		next.mu.Unlock()
+	}
+	stopped := s.stopped
+	s.mu.Unlock()
+	close(s.done)
+	if stopped != nil {
+		<-stopped
+	}
+}
+
+// readScheduleLocation reads SCHEDULE_TZ, which defaults to local time
+func readScheduleLocation() (*time.Location, error) {
+	name := os.Getenv(ScheduleTZEnv)
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ScheduleTZEnv, err)
+	}
+	return loc, nil
+}
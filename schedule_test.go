@@ -0,0 +1,136 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "Mon-Fri 09:00-18:00"},
+		{in: "Sat,Sun"},
+		{in: "22:00-06:00"},
+		{in: "Fri-Mon 20:00-08:00"},
+		{in: "mon 00:00-23:59"},
+		{in: "Mon-Friday 09:00-18:00", wantErr: true},
+		{in: "Mon-Fri 9-18", wantErr: true},
+		{in: "Mon-Fri 09:00", wantErr: true},
+		{in: "25:00-26:00", wantErr: true},
+		{in: "Someday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := parseTimeWindow(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTimeWindow(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2026-10-12 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, 12+day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{window: "Mon-Fri 09:00-18:00", t: at(0, 9, 0), want: true},
+		{window: "Mon-Fri 09:00-18:00", t: at(0, 17, 59), want: true},
+		{window: "Mon-Fri 09:00-18:00", t: at(0, 18, 0), want: false},
+		{window: "Mon-Fri 09:00-18:00", t: at(0, 8, 59), want: false},
+		{window: "Mon-Fri 09:00-18:00", t: at(5, 12, 0), want: false},
+		{window: "Sat,Sun", t: at(5, 0, 0), want: true},
+		{window: "Sat,Sun", t: at(6, 23, 59), want: true},
+		{window: "Sat,Sun", t: at(4, 23, 59), want: false},
+		{window: "22:00-06:00", t: at(0, 23, 0), want: true},
+		{window: "22:00-06:00", t: at(1, 5, 59), want: true},
+		{window: "22:00-06:00", t: at(1, 6, 0), want: false},
+		{window: "22:00-06:00", t: at(1, 12, 0), want: false},
+		// After midnight belongs to the day the window started
+		{window: "Fri 22:00-06:00", t: at(5, 3, 0), want: true},
+		{window: "Fri 22:00-06:00", t: at(4, 3, 0), want: false},
+		{window: "Fri 22:00-06:00", t: at(4, 22, 0), want: true},
+		{window: "Sun-Mon 08:00-10:00", t: at(6, 9, 0), want: true},
+	}
+	for _, tt := range tests {
+		w, err := parseTimeWindow(tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("%q.Contains(%s) = %v, want %v", tt.window, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestScheduleAllow(t *testing.T) {
+	// No window is ever open
+	s, err := parseSchedule(`{"start":[],"info":["Mon 00:00-00:00"]}`, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Allow(&Event{Type: Die, Severity: Critical}) != true {
+		t.Error("unscheduled event suppressed")
+	}
+	if s.Allow(&Event{Type: Start, Severity: Warning}) {
+		t.Error("start event allowed outside of its schedule")
+	}
+	if s.Allow(&Event{Type: Die, Severity: Info}) {
+		t.Error("info event allowed outside of its schedule")
+	}
+	if s.suppressed[Start] != 1 || s.suppressed["info"] != 1 {
+		t.Errorf("suppressed = %v", s.suppressed)
+	}
+}
+
+func TestScheduleClose(t *testing.T) {
+	old, err := parseSchedule(`{"start":[],"info":[]}`, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := parseSchedule(`{"start":[]}`, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Allow(&Event{Type: Start, Severity: Info})
+	old.Allow(&Event{Type: Die, Severity: Info})
+	old.Allow(&Event{Type: Die, Severity: Info})
+
+	var mu sync.Mutex
+	var summaries []*Message
+	go func() {
+		old.Summarize(func(e *Event, m *Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			summaries = append(summaries, m)
+		})
+	}()
+	// Close waits for Summarize once it runs
+	for {
+		old.mu.Lock()
+		running := old.stopped != nil
+		old.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	old.Close(next)
+
+	if next.suppressed[Start] != 1 || len(next.suppressed) != 1 {
+		t.Errorf("next suppressed = %v, want only start", next.suppressed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(summaries) != 1 || summaries[0].Attachments[0].Text != "info: 2" {
+		t.Fatalf("summaries = %+v, want one of info: 2", summaries)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HTTPAddrEnv is key of HTTP_ADDR
+	HTTPAddrEnv = "HTTP_ADDR"
+	// DefaultRecentLimit is number of events returned by /recent
+	DefaultRecentLimit = 20
+)
+
+// Server is HTTP server to inspect docker-notify
+type Server struct {
+	holder *ConfigHolder
+	reload func() error
+	mux    *http.ServeMux
+}
+
+// NewServer is constructor. reload reloads the config of holder.
+func NewServer(holder *ConfigHolder, reload func() error) *Server {
+	s := &Server{
+		holder: holder,
+		reload: reload,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/recent", s.handleRecent)
+	s.mux.HandleFunc("/events", s.handleEvents)
+	s.mux.HandleFunc("/targets", s.handleTargets)
+	s.mux.HandleFunc("/targets/", s.handleTarget)
+	s.mux.HandleFunc("/debug/state", s.handleDebugState)
+	s.mux.HandleFunc("/reload", s.handleReload)
+	return s
+}
+
+// ListenAndServe serves on addr
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.mux)
+}
+
+// handleRecent returns the latest events
+func (s *Server) handleRecent(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.holder.Load().Store.Query(EventQuery{Limit: DefaultRecentLimit}))
+}
+
+// handleEvents returns events filtered by name, type and limit query parameters
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := EventQuery{
+		Name: r.URL.Query().Get("name"),
+		Type: r.URL.Query().Get("type"),
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+	writeJSON(w, s.holder.Load().Store.Query(q))
+}
+
+// handleTargets returns whether each target is enabled
+func (s *Server) handleTargets(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.holder.Load().Switches.States())
+}
+
+// handleTarget enables or disables a target by POST /targets/<name>?enabled=false
+func (s *Server) handleTarget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/targets/")
+	switches := s.holder.Load().Switches
+	if !switches.Set(name, enabled) {
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf("target %s enabled: %t", name, enabled)
+	writeJSON(w, switches.States())
+}
+
+// handleReload reloads the config by POST /reload
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.reload(); err != nil {
+		log.Printf("reload: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, s.holder.Load().debugState())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// LogMinSeverityEnv is key of LOG_MIN_SEVERITY
+	LogMinSeverityEnv = "LOG_MIN_SEVERITY"
+	// SuccessExitCodesEnv is key of SUCCESS_EXIT_CODES
+	SuccessExitCodesEnv = "SUCCESS_EXIT_CODES"
+	// SuppressSuccessEnv is key of SUPPRESS_SUCCESS
+	SuppressSuccessEnv = "SUPPRESS_SUCCESS"
+	// LogStreamsEnv is key of LOG_STREAMS
+	LogStreamsEnv = "LOG_STREAMS"
+	// SeverityLogStreamsEnvFormat is format of LOG_STREAMS_<SEVERITY> keys, e.g. LOG_STREAMS_CRITICAL=stderr
+	SeverityLogStreamsEnvFormat = "LOG_STREAMS_%s"
+	// Stdout is name of the stdout stream of containers
+	Stdout = "stdout"
+	// Stderr is name of the stderr stream of containers
+	Stderr = "stderr"
+)
+
+// Severity is importance of an event
+type Severity int
+
+const (
+	// Info is severity of routine events
+	Info Severity = iota + 1
+	// Warning is severity of events which may need attention
+	Warning
+	// Critical is severity of events which need attention
+	Critical
+)
+
+var severityNames = map[Severity]string{
+	Info:     "info",
+	Warning:  "warning",
+	Critical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSeverity parses name of severity
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for s, n := range severityNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// severityOf classifies msg. OOM kills are critical, unhealthy containers
+// and starts of images from untrusted registries are warning. Exits with one
+// of SuccessExitCodes are info, exits by signal (e.g. 137 after docker stop
+// timed out) are warning and other failures are critical.
+func (c *Config) severityOf(msg *events.Message) Severity {
+	switch eventType(msg) {
+	case Die:
+	case OOM:
+		return Critical
+	case Unhealthy:
+		return Warning
+	case Start:
+		if !c.isTrustedRegistry(msg.From) {
+			return Warning
+		}
+		return Info
+	default:
+		return Info
+	}
+	code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+	switch {
+	case err != nil:
+		return Warning
+	case c.isSuccess(code):
+		return Info
+	case code > 128:
+		return Warning
+	default:
+		return Critical
+	}
+}
+
+func (c *Config) isSuccess(code int) bool {
+	for _, s := range c.SuccessExitCodes {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+// SuppressSuccessFilter skips die events with a success exit code
+func (c *Config) SuppressSuccessFilter(msg *events.Message) bool {
+	if msg.Status != Die {
+		return true
+	}
+	code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+	return err != nil || !c.isSuccess(code)
+}
+
+func parseExitCodes(s string) ([]int, error) {
+	var codes []int
+	for _, v := range splitList(s) {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit code %q", v)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// wantLogs reports whether logs should be attached to the message of msg.
+// Without LOG_MIN_SEVERITY, logs are attached to die messages only.
+func (c *Config) wantLogs(msg *events.Message, s Severity) bool {
+	if c.LogMinSeverity == 0 {
+		return msg.Status == Die
+	}
+	return s >= c.LogMinSeverity
+}
+
+// LogStreams are the streams logs are read from
+type LogStreams struct {
+	Stdout bool `json:"stdout"`
+	Stderr bool `json:"stderr"`
+}
+
+// logStreamsOf returns the streams logs of events of severity s are read
+// from
+func (c *Config) logStreamsOf(s Severity) LogStreams {
+	if streams, ok := c.LogStreams[s]; ok {
+		return streams
+	}
+	return LogStreams{Stdout: true, Stderr: true}
+}
+
+func parseLogStreams(key string, def LogStreams) (LogStreams, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	var streams LogStreams
+	for _, name := range splitList(v) {
+		switch name {
+		case Stdout:
+			streams.Stdout = true
+		case Stderr:
+			streams.Stderr = true
+		default:
+			return streams, fmt.Errorf("%s must be a list of %s and %s", key, Stdout, Stderr)
+		}
+	}
+	if !streams.Stdout && !streams.Stderr {
+		return streams, fmt.Errorf("%s must be a list of %s and %s", key, Stdout, Stderr)
+	}
+	return streams, nil
+}
+
+// parseSeverityLogStreams reads LOG_STREAMS_<SEVERITY> of each severity,
+// which default to LOG_STREAMS, both streams by default
+func parseSeverityLogStreams() (map[Severity]LogStreams, error) {
+	def, err := parseLogStreams(LogStreamsEnv, LogStreams{Stdout: true, Stderr: true})
+	if err != nil {
+		return nil, err
+	}
+	streams := make(map[Severity]LogStreams, len(severityNames))
+	for s, name := range severityNames {
+		if streams[s], err = parseLogStreams(fmt.Sprintf(SeverityLogStreamsEnvFormat, strings.ToUpper(name)), def); err != nil {
+			return nil, err
+		}
+	}
+	return streams, nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
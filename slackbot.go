@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// SlackBotTokenEnv is key of SLACK_BOT_TOKEN
+	SlackBotTokenEnv = "SLACK_BOT_TOKEN"
+	// SlackChannelEnv is key of SLACK_CHANNEL
+	SlackChannelEnv = "SLACK_CHANNEL"
+	// AckTimeoutEnv is key of ACK_TIMEOUT
+	AckTimeoutEnv = "ACK_TIMEOUT"
+	// AckEscalateTargetEnv is key of ACK_ESCALATE_TARGET
+	AckEscalateTargetEnv = "ACK_ESCALATE_TARGET"
+	// DefaultAckTimeout is how long a critical alert waits for an ack
+	DefaultAckTimeout = 15 * time.Minute
+	// AckReaction is reaction the bot offers for acknowledging an alert
+	AckReaction = "white_check_mark"
+
+	slackAPI        = "https://slack.com/api/"
+	ackPollInterval = 30 * time.Second
+)
+
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	TS      string `json:"ts"`
+	Channel string `json:"channel"`
+	UserID  string `json:"user_id"`
+	Message struct {
+		Reactions []struct {
+			Name  string   `json:"name"`
+			Users []string `json:"users"`
+		} `json:"reactions"`
+	} `json:"message"`
+}
+
+// pendingAck is a critical alert waiting to be acknowledged
+type pendingAck struct {
+	channel  string
+	ts       string
+	deadline time.Time
+	e        *Event
+	m        *Message
+}
+
+// SlackBotTarget posts messages with a Slack bot token. Critical alerts get
+// an ack reaction, and when nobody reacts to them within timeout they are
+// re-notified, or escalated to another target.
+type SlackBotTarget struct {
+	token    string
+	channel  string
+	timeout  time.Duration
+	escalate Target
+	// allow reports whether an alert may be escalated to escalate, like
+	// other messages sent to it, when it is set
+	allow func(e *Event, name string) bool
+	// userID of the bot is only used by watch, which asks Slack for it
+	userID string
+
+	mu      sync.Mutex
+	pending []*pendingAck
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSlackBotTarget is constructor. The token is checked when acks are
+// first watched, so building a config doesn't wait for Slack.
+func NewSlackBotTarget(token, channel string, timeout time.Duration) *SlackBotTarget {
+	t := &SlackBotTarget{
+		token:   token,
+		channel: channel,
+		timeout: timeout,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.watch()
+	return t
+}
+
+// Name returns name of target
+func (t *SlackBotTarget) Name() string {
+	return "slackbot"
+}
+
+// Send posts m to the channel and tracks acks of critical alerts
+func (t *SlackBotTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	var posted slackResponse
+	if err := t.call(ctx, "chat.postMessage", map[string]interface{}{
+		"channel":     t.channel,
+		"text":        m.Text,
+		"attachments": m.Attachments,
+	}, &posted); err != nil {
+		return err
+	}
+	if e.Severity < Critical {
+		return nil
+	}
+	if err := t.call(ctx, "reactions.add", map[string]interface{}{
+		"channel":   posted.Channel,
+		"timestamp": posted.TS,
+		"name":      AckReaction,
+	}, nil); err != nil {
+		log.Printf("%s: %v", t.Name(), err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, &pendingAck{
+		channel:  posted.Channel,
+		ts:       posted.TS,
+		deadline: time.Now().Add(t.timeout),
+		e:        e,
+		m:        m,
+	})
+	return nil
+}
+
+// watch checks pending alerts for acks until they are acked or escalated
+func (t *SlackBotTarget) watch() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(ackPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+		t.mu.Lock()
+		pending := t.pending
+		t.pending = nil
+		t.mu.Unlock()
+
+		var waiting []*pendingAck
+		for _, p := range pending {
+			acked, err := t.acked(p)
+			if err != nil {
+				log.Printf("%s: %v", t.Name(), err)
+			}
+			switch {
+			case acked:
+			case time.Now().After(p.deadline):
+				t.escalateAck(p)
+			default:
+				waiting = append(waiting, p)
+			}
+		}
+
+		t.mu.Lock()
+		t.pending = append(t.pending, waiting...)
+		t.mu.Unlock()
+	}
+}
+
+// Close stops watching acks. Alerts still waiting for an ack are handed
+// over to next, the bot replacing t, when it isn't nil.
+func (t *SlackBotTarget) Close(next *SlackBotTarget) {
+	close(t.done)
+	<-t.stopped
+	if next == nil {
+		return
+	}
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	next.pending = append(next.pending, pending...)
+}
+
+// identity returns the user ID of the bot, asking Slack until it is known
+func (t *SlackBotTarget) identity() (string, error) {
+	if t.userID != "" {
+		return t.userID, nil
+	}
+	var auth slackResponse
+	if err := t.call(context.Background(), "auth.test", nil, &auth); err != nil {
+		return "", fmt.Errorf("%s: %v", SlackBotTokenEnv, err)
+	}
+	t.userID = auth.UserID
+	return t.userID, nil
+}
+
+// acked reports whether somebody but the bot reacted to p
+func (t *SlackBotTarget) acked(p *pendingAck) (bool, error) {
+	userID, err := t.identity()
+	if err != nil {
+		return false, err
+	}
+	var resp slackResponse
+	q := url.Values{"channel": {p.channel}, "timestamp": {p.ts}}
+	if err := t.call(context.Background(), "reactions.get?"+q.Encode(), nil, &resp); err != nil {
+		return false, err
+	}
+	for _, r := range resp.Message.Reactions {
+		for _, u := range r.Users {
+			if u != userID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// escalateAck sends p to the escalation target, or reminds of p in its
+// thread when there is none or it is muted or not routed
+func (t *SlackBotTarget) escalateAck(p *pendingAck) {
+	if t.escalate != nil && (t.allow == nil || t.allow(p.e, t.escalate.Name())) {
+		if err := t.escalate.Send(context.Background(), p.e, p.m); err != nil {
+			log.Printf("%s: %v", t.escalate.Name(), err)
+		}
+		return
+	}
+	if err := t.call(context.Background(), "chat.postMessage", map[string]interface{}{
+		"channel":         p.channel,
+		"thread_ts":       p.ts,
+		"reply_broadcast": true,
+		"text":            fmt.Sprintf("Not acknowledged for %s. React with :%s: to acknowledge.", t.timeout, AckReaction),
+	}, nil); err != nil {
+		log.Printf("%s: %v", t.Name(), err)
+	}
+}
+
+// call calls a Slack Web API method. GET is used when body is nil.
+func (t *SlackBotTarget) call(ctx context.Context, method string, body interface{}, v *slackResponse) error {
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, slackAPI+method, nil)
+	} else {
+		var b []byte
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, slackAPI+method, bytes.NewBuffer(b)); err == nil {
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var r slackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return errors.New(r.Error)
+	}
+	if v != nil {
+		*v = r
+	}
+	return nil
+}
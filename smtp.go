@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SMTPHostEnv is key of SMTP_HOST, e.g. smtp.example.com:587
+	SMTPHostEnv = "SMTP_HOST"
+	// SMTPFromEnv is key of SMTP_FROM
+	SMTPFromEnv = "SMTP_FROM"
+	// SMTPToEnv is key of SMTP_TO
+	SMTPToEnv = "SMTP_TO"
+	// SMTPUsernameEnv is key of SMTP_USERNAME
+	SMTPUsernameEnv = "SMTP_USERNAME"
+	// SMTPPasswordEnv is key of SMTP_PASSWORD
+	SMTPPasswordEnv = "SMTP_PASSWORD"
+	// SMTPDigestIntervalEnv is key of SMTP_DIGEST_INTERVAL
+	SMTPDigestIntervalEnv = "SMTP_DIGEST_INTERVAL"
+	// DefaultSMTPDigestInterval is how often digests are mailed
+	DefaultSMTPDigestInterval = 24 * time.Hour
+	// smtpMaxEvents is number of events kept for a digest, older ones are
+	// only counted
+	smtpMaxEvents = 1000
+	// smtpTimeout bounds connecting to the mail server, and mailing a
+	// digest once connected
+	smtpTimeout = 30 * time.Second
+)
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<html><body>
+<h2>{{.Total}} container events since {{.Since.Format "2006-01-02 15:04 MST"}}</h2>
+<ul>{{range .Counts}}<li>{{.}}</li>{{end}}</ul>
+{{if .Dropped}}<p>{{.Dropped}} older events are not listed.</p>{{end}}
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Time</th><th>Event</th><th>Severity</th><th>Container</th><th>Image</th><th>Exit code</th></tr>
+{{range .Events}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Type}}</td><td>{{.Severity}}</td><td>{{.Name}}</td><td>{{.Image}}</td><td>{{.ExitCode}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// SMTPTarget mails a digest of events every interval instead of a mail per
+// event. Mails are sent in the background, so a failing mail server doesn't
+// delay other targets.
+type SMTPTarget struct {
+	addr     string
+	from     string
+	to       []string
+	auth     smtp.Auth
+	interval time.Duration
+
+	mu      sync.Mutex
+	since   time.Time
+	events  []*Event
+	dropped int
+	counts  map[string]int
+	added   int
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSMTPTarget is constructor. It starts mailing digests in the background.
+func NewSMTPTarget(addr, from string, to []string, auth smtp.Auth, interval time.Duration) *SMTPTarget {
+	t := &SMTPTarget{
+		addr:     addr,
+		from:     from,
+		to:       to,
+		auth:     auth,
+		interval: interval,
+		since:    time.Now(),
+		counts:   make(map[string]int),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Name returns name of target
+func (t *SMTPTarget) Name() string {
+	return "smtp"
+}
+
+// Send adds e, or each event of a batched m, to the next digest
+func (t *SMTPTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	if e.Type == Summary {
+		return nil
+	}
+	events := []*Event{e}
+	if m != nil && len(m.events) > 0 {
+		events = m.events
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range events {
+		t.counts[fmt.Sprintf("%s %s", e.Severity, e.Type)]++
+		if len(t.events) == smtpMaxEvents {
+			t.events = t.events[1:]
+			t.dropped++
+		}
+		t.events = append(t.events, e)
+		t.added++
+	}
+	return nil
+}
+
+// run mails a digest every interval until t is closed
+func (t *SMTPTarget) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+		if err := t.mailDigest(); err != nil {
+			log.Printf("%s: %v", t.Name(), err)
+		}
+	}
+}
+
+// Close stops mailing digests. The events of the pending digest are handed
+// over to next, the target replacing t, or mailed when next is nil.
+func (t *SMTPTarget) Close(next *SMTPTarget) {
+	close(t.done)
+	select {
+	case <-t.stopped:
+	case <-time.After(2 * smtpTimeout):
+		log.Printf("%s: timed out waiting for the digest being mailed", t.Name())
+	}
+	if next == nil {
+		if err := t.mailDigest(); err != nil {
+			log.Printf("%s: %v", t.Name(), err)
+		}
+		return
+	}
+	t.mu.Lock()
+	events, dropped, since := t.events, t.dropped, t.since
+	t.events = nil
+	t.mu.Unlock()
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	for _, e := range events {
+		next.counts[fmt.Sprintf("%s %s", e.Severity, e.Type)]++
+		if len(next.events) == smtpMaxEvents {
+			next.events = next.events[1:]
+			next.dropped++
+		}
+		next.events = append(next.events, e)
+		next.added++
+	}
+	next.dropped += dropped
+	if since.Before(next.since) {
+		next.since = since
+	}
+}
+
+// mailDigest mails the events since the last digest. They are kept for the
+// next digest when mailing fails.
+func (t *SMTPTarget) mailDigest() error {
+	t.mu.Lock()
+	if len(t.events) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	data := struct {
+		Since   time.Time
+		Total   int
+		Counts  []string
+		Dropped int
+		Events  []*Event
+	}{
+		Since:   t.since,
+		Total:   len(t.events) + t.dropped,
+		Dropped: t.dropped,
+		Events:  append([]*Event(nil), t.events...),
+	}
+	// Events handed over on a reload come after the newer ones
+	sort.SliceStable(data.Events, func(i, j int) bool {
+		return data.Events[i].Time.Before(data.Events[j].Time)
+	})
+	for k, n := range t.counts {
+		data.Counts = append(data.Counts, fmt.Sprintf("%s: %d", k, n))
+	}
+	added := t.added
+	t.mu.Unlock()
+	sort.Strings(data.Counts)
+
+	var body bytes.Buffer
+	if err := digestTemplate.Execute(&body, data); err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", t.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(t.to, ", "))
+	fmt.Fprintf(&msg, "Subject: docker-notify: %d container events\r\n", data.Total)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	msg.Write(body.Bytes())
+	if err := t.sendMail(msg.Bytes()); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Events which arrived while mailing go to the next digest
+	if n := t.added - added; n < len(t.events) {
+		t.events = t.events[len(t.events)-n:]
+	}
+	t.dropped = 0
+	t.counts = make(map[string]int)
+	for _, e := range t.events {
+		t.counts[fmt.Sprintf("%s %s", e.Severity, e.Type)]++
+	}
+	t.since = time.Now()
+	return nil
+}
+
+// sendMail is smtp.SendMail bounded by smtpTimeout, so a hung mail server
+// doesn't stop digests for good
+func (t *SMTPTarget) sendMail(msg []byte) error {
+	host, _, err := net.SplitHostPort(t.addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", t.addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && t.auth != nil {
+		if err := c.Auth(t.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(t.from); err != nil {
+		return err
+	}
+	for _, to := range t.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// newSMTPTarget builds the target configured by SMTP_* keys
+func newSMTPTarget(addr string) (*SMTPTarget, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SMTPHostEnv, err)
+	}
+	from := os.Getenv(SMTPFromEnv)
+	to := splitList(os.Getenv(SMTPToEnv))
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("%s and %s must be set with %s", SMTPFromEnv, SMTPToEnv, SMTPHostEnv)
+	}
+	var auth smtp.Auth
+	if username := os.Getenv(SMTPUsernameEnv); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv(SMTPPasswordEnv), host)
+	}
+	interval, err := parseDuration(SMTPDigestIntervalEnv, DefaultSMTPDigestInterval)
+	if err != nil {
+		return nil, err
+	}
+	return NewSMTPTarget(addr, from, to, auth, interval), nil
+}
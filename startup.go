@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// StartupNoticeEnv is key of STARTUP_NOTICE
+	StartupNoticeEnv = "STARTUP_NOTICE"
+	// Startup is type of the startup notice
+	Startup = "startup"
+)
+
+// startupMessage tells that docker-notify started watching the daemon,
+// with its version and host for fleets which report into one channel
+func startupMessage(cli *client.Client, config *Config) (*Event, *Message) {
+	ctx, cancel := config.eventContext()
+	defer cancel()
+	now := time.Now()
+	host, _ := os.Hostname()
+	var fields []Field
+	if info, err := cli.Info(ctx); err == nil {
+		host = info.Name
+		fields = append(fields,
+			Field{Title: "OS", Value: fmt.Sprintf("%s (%s)", info.OperatingSystem, info.KernelVersion), Short: true},
+			Field{Title: "architecture", Value: info.Architecture, Short: true},
+		)
+	}
+	if version, err := cli.ServerVersion(ctx); err == nil {
+		fields = append([]Field{
+			{Title: "docker", Value: fmt.Sprintf("%s (API %s)", version.Version, version.APIVersion), Short: true},
+		}, fields...)
+	}
+	e := &Event{
+		Time:     now,
+		Type:     Startup,
+		Name:     host,
+		Severity: Info,
+	}
+	m := &Message{
+		Attachments: []Attachment{
+			{
+				Title:  fmt.Sprintf("docker-notify started on %s", host),
+				Color:  StartColor,
+				TS:     now.Unix(),
+				Fields: fields,
+			},
+		},
+	}
+	return e, m
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// EventStoreSizeEnv is key of EVENT_STORE_SIZE
+	EventStoreSizeEnv = "EVENT_STORE_SIZE"
+	// EventStoreRetentionEnv is key of EVENT_STORE_RETENTION
+	EventStoreRetentionEnv = "EVENT_STORE_RETENTION"
+	// DefaultEventStoreSize is number of events kept in the store
+	DefaultEventStoreSize = 1000
+	// DefaultEventStoreRetention is how long events are kept in the store
+	DefaultEventStoreRetention = 24 * time.Hour
+)
+
+// EventStore keeps recent events in memory, bounded by count and age
+type EventStore struct {
+	size      int
+	retention time.Duration
+
+	mu     sync.Mutex
+	events []*Event
+}
+
+// NewEventStore is constructor
+func NewEventStore(size int, retention time.Duration) *EventStore {
+	return &EventStore{
+		size:      size,
+		retention: retention,
+	}
+}
+
+// Add records e, evicting the oldest events beyond the bounds
+func (s *EventStore) Add(e *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	if len(s.events) > s.size {
+		s.events = append(s.events[:0], s.events[len(s.events)-s.size:]...)
+	}
+	s.expire(time.Now())
+}
+
+// EventQuery selects events of Query. Empty fields match all events.
+type EventQuery struct {
+	Name  string
+	Type  string
+	Limit int
+}
+
+// Query returns events matching q, newest first
+func (s *EventStore) Query(q EventQuery) []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	result := []*Event{}
+	for i := len(s.events) - 1; i >= 0; i-- {
+		e := s.events[i]
+		if q.Name != "" && e.Name != q.Name {
+			continue
+		}
+		if q.Type != "" && e.Type != q.Type {
+			continue
+		}
+		result = append(result, e)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// expire drops events older than retention. It must be called with mu held.
+func (s *EventStore) expire(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+	i := 0
+	for i < len(s.events) && now.Sub(s.events[i].Time) > s.retention {
+		i++
+	}
+	if i > 0 {
+		s.events = append(s.events[:0], s.events[i:]...)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// TargetEnabledEnvFormat is format of <TARGET>_ENABLED keys, e.g. SLACK_ENABLED
+	TargetEnabledEnvFormat = "%s_ENABLED"
+)
+
+// Target is destination of notifications
+type Target interface {
+	Name() string
+	Send(ctx context.Context, e *Event, m *Message) error
+}
+
+// WebhookTarget posts Slack formatted messages to a webhook URL
+type WebhookTarget struct {
+	name string
+	url  *URLTemplate
+	lang string
+}
+
+// NewWebhookTarget is constructor. lang is the language hint of log code
+// blocks.
+func NewWebhookTarget(name string, url *URLTemplate, lang string) *WebhookTarget {
+	return &WebhookTarget{
+		name: name,
+		url:  url,
+		lang: lang,
+	}
+}
+
+// Name returns name of target
+func (t *WebhookTarget) Name() string {
+	return t.name
+}
+
+// Send posts m to the webhook
+func (t *WebhookTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	u, err := t.url.Render(e)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(withLogLanguage(m, t.lang))
+	if err != nil {
+		return err
+	}
+	return m.post(ctx, u, b)
+}
+
+// notify sends e and m to all enabled targets it is routed to
+func (c *Config) notify(ctx context.Context, e *Event, m *Message) {
+	for _, t := range c.Targets {
+		if !c.allowed(e, t.Name()) {
+			continue
+		}
+		span := spanFrom(ctx).Child("send " + t.Name())
+		span.SetAttr("docker_notify.target", t.Name())
+		err := t.Send(ctx, e, m)
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			c.Errors.Inc(t.Name())
+			if ctx.Err() != nil {
+				log.Printf("%s: %s exceeded: %v", t.Name(), EventDeadlineEnv, err)
+				continue
+			}
+			log.Printf("%s: %v", t.Name(), err)
+		}
+	}
+}
+
+// allowed reports whether e may be sent to target name, which is when name
+// is enabled and e is routed to it
+func (c *Config) allowed(e *Event, name string) bool {
+	return c.Switches.Enabled(name) && c.routed(e, name)
+}
+
+// routed reports whether e is sent to target name, by the targets set by a
+// rule or by ROUTES
+func (c *Config) routed(e *Event, name string) bool {
+	if len(e.targets) == 0 {
+		return c.Routes.Routed(e, name)
+	}
+	for _, t := range e.targets {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// postJSON posts body to u, bounded by ctx
+func postJSON(ctx context.Context, u string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
+}
+
+// newTargets builds the targets configured by the environment
+func newTargets(cli *client.Client, slackURL, discordURL string) ([]Target, error) {
+	allowlist := splitList(os.Getenv(URLHostAllowlistEnv))
+	var targets []Target
+	built := false
+	defer func() {
+		// Don't leak the targets built before one failed
+		if !built {
+			closeTargets(targets, nil)
+		}
+	}()
+	if slackURL != "" {
+		u, err := NewURLTemplate(slackURL, allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", SlackURLEnv, err)
+		}
+		lang, err := parseLogLanguage("slack")
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, NewWebhookTarget("slack", u, lang))
+	}
+	if discordURL != "" {
+		// "/slack" was required when messages were sent in Slack format
+		u, err := NewURLTemplate(strings.TrimSuffix(discordURL, "/slack"), allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", DiscordURLEnv, err)
+		}
+		overflow := os.Getenv(DiscordOverflowEnv)
+		switch overflow {
+		case "":
+			overflow = DiscordSplit
+		case DiscordSplit, DiscordTruncate:
+		default:
+			return nil, fmt.Errorf("%s must be %s or %s", DiscordOverflowEnv, DiscordSplit, DiscordTruncate)
+		}
+		maxPosts, err := parseInt(DiscordMaxPostsEnv, DefaultDiscordMaxPosts)
+		if err != nil {
+			return nil, err
+		}
+		colors, err := parseDiscordColors(EventTypes)
+		if err != nil {
+			return nil, err
+		}
+		lang, err := parseLogLanguage("discord")
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, NewDiscordTarget(u, overflow, maxPosts, colors, lang))
+	}
+	if path := os.Getenv(LogFileEnv); path != "" {
+		maxBytes, err := parseInt(LogFileMaxBytesEnv, DefaultLogFileMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		backups, err := parseInt(LogFileBackupsEnv, DefaultLogFileBackups)
+		if err != nil {
+			return nil, err
+		}
+		t, err := NewLogFileTarget(path, int64(maxBytes), backups)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if teamsURL := os.Getenv(TeamsURLEnv); teamsURL != "" {
+		u, err := NewURLTemplate(teamsURL, allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", TeamsURLEnv, err)
+		}
+		t, err := NewTeamsTarget(u, os.Getenv(TeamsFormatEnv))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if callbackURL := os.Getenv(CallbackURLEnv); callbackURL != "" {
+		u, err := NewURLTemplate(callbackURL, allowlist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", CallbackURLEnv, err)
+		}
+		t, err := NewCallbackTarget(u, cli, splitList(os.Getenv(CallbackActionsEnv)))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if esURL := os.Getenv(ESURLEnv); esURL != "" {
+		t, err := newElasticsearchTarget(cli, esURL)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if smtpHost := os.Getenv(SMTPHostEnv); smtpHost != "" {
+		t, err := newSMTPTarget(smtpHost)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	var bot *SlackBotTarget
+	if token := os.Getenv(SlackBotTokenEnv); token != "" {
+		channel := os.Getenv(SlackChannelEnv)
+		if channel == "" {
+			return nil, fmt.Errorf("%s must be set with %s", SlackChannelEnv, SlackBotTokenEnv)
+		}
+		timeout, err := parseDuration(AckTimeoutEnv, DefaultAckTimeout)
+		if err != nil {
+			return nil, err
+		}
+		bot = NewSlackBotTarget(token, channel, timeout)
+		targets = append(targets, bot)
+	}
+	measured, err := measureTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	limited, err := limitTargets(measured)
+	if err != nil {
+		return nil, err
+	}
+	targets = limited
+	if name := os.Getenv(LatencyAlertTargetEnv); name != "" {
+		alert := findTarget(targets, name)
+		if alert == nil {
+			return nil, fmt.Errorf("%s: unknown target %q", LatencyAlertTargetEnv, name)
+		}
+		for _, t := range targets {
+			if measured := latencyTarget(t); measured != nil && t != alert {
+				measured.alert = alert
+			}
+		}
+	}
+	if name := os.Getenv(AckEscalateTargetEnv); bot != nil && name != "" {
+		if bot.escalate = findTarget(targets, name); bot.escalate == nil || name == bot.Name() {
+			return nil, fmt.Errorf("%s: unknown target %q", AckEscalateTargetEnv, name)
+		}
+	}
+	built = true
+	return targets, nil
+}
+
+// findTarget returns target name, or nil
+func findTarget(targets []Target, name string) Target {
+	for _, t := range targets {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// TargetSwitch enables and disables targets at runtime
+type TargetSwitch struct {
+	mu      sync.RWMutex
+	enabled map[string]bool
+}
+
+// newTargetSwitch reads <TARGET>_ENABLED of each target, which defaults to
+// true
+func newTargetSwitch(targets []Target) (*TargetSwitch, error) {
+	s := &TargetSwitch{
+		enabled: make(map[string]bool),
+	}
+	for _, t := range targets {
+		enabled, err := parseBool(fmt.Sprintf(TargetEnabledEnvFormat, strings.ToUpper(t.Name())), true)
+		if err != nil {
+			return nil, err
+		}
+		s.enabled[t.Name()] = enabled
+	}
+	return s, nil
+}
+
+// Enabled reports whether target name is enabled
+func (s *TargetSwitch) Enabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.enabled[name]
+}
+
+// Set enables or disables target name. It returns false for unknown targets.
+func (s *TargetSwitch) Set(name string, enabled bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.enabled[name]; !ok {
+		return false
+	}
+	s.enabled[name] = enabled
+	return true
+}
+
+// States returns whether each target is enabled
+func (s *TargetSwitch) States() map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make(map[string]bool, len(s.enabled))
+	for name, enabled := range s.enabled {
+		states[name] = enabled
+	}
+	return states
+}
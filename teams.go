@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// TeamsURLEnv is key of TEAMS_URL
+	TeamsURLEnv = "TEAMS_URL"
+	// TeamsFormatEnv is key of TEAMS_FORMAT
+	TeamsFormatEnv = "TEAMS_FORMAT"
+	// TeamsAuto picks the format by the URL: MessageCard for connector
+	// webhooks, Adaptive Card for everything else
+	TeamsAuto = "auto"
+	// TeamsMessageCard is the legacy format of Office 365 connectors
+	TeamsMessageCard = "messagecard"
+	// TeamsAdaptiveCard is the format of Power Automate workflows
+	TeamsAdaptiveCard = "adaptivecard"
+
+	teamsConnectorHost = "webhook.office.com"
+)
+
+// TeamsTarget posts messages to Microsoft Teams
+type TeamsTarget struct {
+	url    *URLTemplate
+	format string
+}
+
+// NewTeamsTarget is constructor
+func NewTeamsTarget(url *URLTemplate, format string) (*TeamsTarget, error) {
+	switch format {
+	case "", TeamsAuto:
+		format = TeamsAdaptiveCard
+		if strings.Contains(url.String(), teamsConnectorHost) {
+			format = TeamsMessageCard
+		}
+	case TeamsMessageCard, TeamsAdaptiveCard:
+	default:
+		return nil, fmt.Errorf("%s must be %s, %s or %s", TeamsFormatEnv, TeamsAuto, TeamsMessageCard, TeamsAdaptiveCard)
+	}
+	return &TeamsTarget{
+		url:    url,
+		format: format,
+	}, nil
+}
+
+// Name returns name of target
+func (t *TeamsTarget) Name() string {
+	return "teams"
+}
+
+// Send posts m as a card
+func (t *TeamsTarget) Send(ctx context.Context, e *Event, m *Message) error {
+	u, err := t.url.Render(e)
+	if err != nil {
+		return err
+	}
+	var card interface{}
+	if t.format == TeamsMessageCard {
+		card = messageCard(m)
+	} else {
+		card = adaptiveCard(e, m)
+	}
+	b, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, u, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// messageCard renders m in the legacy MessageCard format
+func messageCard(m *Message) map[string]interface{} {
+	var sections []map[string]interface{}
+	summary := m.Text
+	for _, a := range m.Attachments {
+		if summary == "" {
+			summary = a.Title
+		}
+		facts := make([]map[string]string, 0, len(a.Fields))
+		for _, f := range a.Fields {
+			facts = append(facts, map[string]string{"name": f.Title, "value": f.Value})
+		}
+		section := map[string]interface{}{
+			"activityTitle": a.Title,
+			"facts":         facts,
+		}
+		if a.logs != "" {
+			section["text"] = "<pre>" + htmlEscape(a.logs) + "</pre>"
+		} else if a.Text != "" {
+			section["text"] = a.Text
+		}
+		sections = append(sections, section)
+	}
+	card := map[string]interface{}{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  summary,
+		"sections": sections,
+	}
+	if len(m.Attachments) > 0 {
+		card["themeColor"] = strings.TrimPrefix(m.Attachments[0].Color, "#")
+	}
+	if m.Text != "" {
+		card["title"] = m.Text
+	}
+	return card
+}
+
+// adaptiveCard renders m as an Adaptive Card message for workflows
+func adaptiveCard(e *Event, m *Message) map[string]interface{} {
+	color := "good"
+	switch e.Severity {
+	case Warning:
+		color = "warning"
+	case Critical:
+		color = "attention"
+	}
+	var body []map[string]interface{}
+	if m.Text != "" {
+		body = append(body, map[string]interface{}{
+			"type": "TextBlock", "text": m.Text, "weight": "Bolder", "size": "Medium", "wrap": true,
+		})
+	}
+	for _, a := range m.Attachments {
+		body = append(body, map[string]interface{}{
+			"type": "TextBlock", "text": a.Title, "weight": "Bolder", "color": color, "wrap": true,
+		})
+		if len(a.Fields) > 0 {
+			facts := make([]map[string]string, 0, len(a.Fields))
+			for _, f := range a.Fields {
+				facts = append(facts, map[string]string{"title": f.Title, "value": f.Value})
+			}
+			body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+		}
+		text := a.Text
+		if a.logs != "" {
+			text = a.logs
+		}
+		if text != "" {
+			body = append(body, map[string]interface{}{
+				"type": "TextBlock", "text": text, "fontType": "Monospace", "wrap": true,
+			})
+		}
+		if a.Footer != "" {
+			body = append(body, map[string]interface{}{
+				"type": "TextBlock", "text": a.Footer, "isSubtle": true, "size": "Small", "wrap": true,
+			})
+		}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+}
+
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func htmlEscape(s string) string {
+	return htmlReplacer.Replace(s)
+}
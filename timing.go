@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+const (
+	// CreateToStartField is title of the field of time between create and
+	// start of a container
+	CreateToStartField = "create to start"
+)
+
+// addCreateToStart shows how long the container of start event e took from
+// its create, e.g. pulling its image. Starts without an observed create, e.g.
+// restarts, have no field.
+func (c *Config) addCreateToStart(m *Message, e *Event) {
+	if e.Type != Start {
+		return
+	}
+	created, ok := c.Cache.Created(e.ID)
+	if !ok || e.Time.Before(created) {
+		return
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: CreateToStartField,
+		Value: e.Time.Sub(created).Round(time.Millisecond).String(),
+		Short: true,
+	})
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OTLPEndpointEnv is key of OTEL_EXPORTER_OTLP_ENDPOINT, e.g. http://collector:4318
+	OTLPEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// OTLPTracesEndpointEnv is key of OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+	OTLPTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	// OTLPHeadersEnv is key of OTEL_EXPORTER_OTLP_HEADERS, e.g. authorization=Bearer token
+	OTLPHeadersEnv = "OTEL_EXPORTER_OTLP_HEADERS"
+	// OTelServiceNameEnv is key of OTEL_SERVICE_NAME
+	OTelServiceNameEnv = "OTEL_SERVICE_NAME"
+	// DefaultOTelServiceName is service name of the spans
+	DefaultOTelServiceName = "docker-notify"
+	// traceBatchSize is number of spans exported by one request
+	traceBatchSize = 256
+	// traceFlushInterval is how long spans wait for a full batch
+	traceFlushInterval = 5 * time.Second
+	// traceCloseTimeout bounds exporting the spans left on Close
+	traceCloseTimeout = 15 * time.Second
+	// traceQueueSize is number of spans waiting to be exported before new
+	// spans are dropped
+	traceQueueSize = 2048
+)
+
+// OTLP span kinds and status codes. Spans which didn't fail keep the unset
+// status.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusError      = 2
+)
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+// Tracer exports spans of event processing to an OpenTelemetry collector
+// with OTLP/HTTP JSON. A nil Tracer creates nil spans, which do nothing.
+type Tracer struct {
+	url      string
+	headers  map[string]string
+	resource []otlpKeyValue
+
+	queue   chan otlpSpan
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewTracer is constructor. It starts exporting spans in the background.
+func NewTracer(u string, headers map[string]string, service string) *Tracer {
+	t := &Tracer{
+		url:      u,
+		headers:  headers,
+		resource: []otlpKeyValue{stringAttr("service.name", service)},
+		queue:    make(chan otlpSpan, traceQueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Span is a span being recorded. Its methods may be called on nil.
+type Span struct {
+	tracer *Tracer
+	span   otlpSpan
+	start  time.Time
+
+	mu sync.Mutex
+}
+
+// Start starts a root span. traceID is used when it is a W3C trace ID, e.g.
+// a correlation ID shared with the traced app, a random one otherwise.
+func (t *Tracer) Start(name, traceID string) *Span {
+	if t == nil {
+		return nil
+	}
+	if b, err := hex.DecodeString(traceID); err != nil || len(b) != 16 {
+		traceID = randomHex(16)
+	}
+	return t.start(name, spanKindInternal, traceID, "")
+}
+
+func (t *Tracer) start(name string, kind int, traceID, parentID string) *Span {
+	return &Span{
+		tracer: t,
+		start:  time.Now(),
+		span: otlpSpan{
+			TraceID:      strings.ToLower(traceID),
+			SpanID:       randomHex(8),
+			ParentSpanID: parentID,
+			Name:         name,
+			Kind:         kind,
+		},
+	}
+}
+
+// Child starts a span of a client call within s
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.start(name, spanKindClient, s.span.TraceID, s.span.SpanID)
+}
+
+// SetAttr sets attribute key to v, a string, int or bool
+func (s *Span) SetAttr(key string, v interface{}) {
+	if s == nil {
+		return
+	}
+	var kv otlpKeyValue
+	switch v := v.(type) {
+	case int:
+		n := strconv.Itoa(v)
+		kv = otlpKeyValue{Key: key, Value: otlpValue{IntValue: &n}}
+	case bool:
+		kv = otlpKeyValue{Key: key, Value: otlpValue{BoolValue: &v}}
+	default:
+		kv = stringAttr(key, fmt.Sprint(v))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes = append(s.span.Attributes, kv)
+}
+
+// SetError marks s as failed by err
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Status = otlpStatus{Code: statusError, Message: err.Error()}
+}
+
+// End ends s and queues it for export. Spans are dropped when the queue is
+// full.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	span := s.span
+	s.mu.Unlock()
+	span.StartTimeUnixNano = strconv.FormatInt(s.start.UnixNano(), 10)
+	span.EndTimeUnixNano = strconv.FormatInt(time.Now().UnixNano(), 10)
+	select {
+	case s.tracer.queue <- span:
+	default:
+	}
+}
+
+type spanKey struct{}
+
+// withSpan returns ctx carrying s
+func withSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// spanFrom returns the span carried by ctx, or nil
+func spanFrom(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// eventSpan starts the span of processing e
+func (c *Config) eventSpan(e *Event) *Span {
+	span := c.Tracer.Start("event "+e.Type, e.CorrelationID)
+	if span == nil {
+		return nil
+	}
+	span.SetAttr("container.id", e.ID)
+	span.SetAttr("container.name", e.Name)
+	span.SetAttr("container.image.name", e.Image)
+	span.SetAttr("docker.event.type", e.Type)
+	span.SetAttr("docker_notify.severity", e.Severity.String())
+	if code, err := strconv.Atoi(e.ExitCode); err == nil {
+		span.SetAttr("docker.exit_code", code)
+	}
+	return span
+}
+
+// run exports queued spans when a batch is full or traceFlushInterval
+// passed. When t is closed, it exports the spans queued so far and returns.
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	var batch []otlpSpan
+	closed := false
+	for !closed {
+		select {
+		case span := <-t.queue:
+			if batch = append(batch, span); len(batch) < traceBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-t.done:
+			closed = true
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := t.export(batch); err != nil {
+			log.Printf("traces: dropping %d spans: %v", len(batch), err)
+		}
+		batch = nil
+	}
+}
+
+// Close exports the queued spans, waiting at most traceCloseTimeout, and
+// stops. Close is nil-safe.
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	close(t.done)
+	select {
+	case <-t.stopped:
+	case <-time.After(traceCloseTimeout):
+		log.Printf("traces: timed out exporting the last spans")
+	}
+}
+
+func (t *Tracer) export(spans []otlpSpan) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": t.resource},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": DefaultOTelServiceName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, b)
+	}
+	return nil
+}
+
+func stringAttr(key, v string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{StringValue: &v}}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newTracer builds the tracer configured by OTEL_* keys, or nil when no
+// endpoint is set
+func newTracer() (*Tracer, error) {
+	u := os.Getenv(OTLPTracesEndpointEnv)
+	if u == "" {
+		endpoint := os.Getenv(OTLPEndpointEnv)
+		if endpoint == "" {
+			return nil, nil
+		}
+		u = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	headers := make(map[string]string)
+	for _, h := range splitList(os.Getenv(OTLPHeadersEnv)) {
+		i := strings.Index(h, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s must be like key=value,key=value", OTLPHeadersEnv)
+		}
+		headers[strings.TrimSpace(h[:i])] = strings.TrimSpace(h[i+1:])
+	}
+	service := os.Getenv(OTelServiceNameEnv)
+	if service == "" {
+		service = DefaultOTelServiceName
+	}
+	return NewTracer(u, headers, service), nil
+}
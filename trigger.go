@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// Kill is identifier of kill event, sent by docker kill and docker stop
+	Kill = "kill"
+	// ExecStartPrefix is prefix of exec_start events, followed by the command
+	ExecStartPrefix = "exec_start: "
+	// TriggeredByField is title of the field of what triggered a die event
+	TriggeredByField = "triggered by"
+	// triggerWindow is how long a kill or oom explains a following die
+	triggerWindow = time.Minute
+	// execWindow is how long an exec explains a following die. It is short
+	// since most execs don't stop the container.
+	execWindow = 5 * time.Second
+	// swarmTaskLabel is set on containers of swarm tasks
+	swarmTaskLabel = "com.docker.swarm.task.id"
+)
+
+var signalNames = map[string]string{
+	"1":  "SIGHUP",
+	"2":  "SIGINT",
+	"3":  "SIGQUIT",
+	"6":  "SIGABRT",
+	"9":  "SIGKILL",
+	"15": "SIGTERM",
+}
+
+// recordTrigger remembers kills, OOMs, execs and health of a container, which
+// explain why the container dies next
+func (c *Config) recordTrigger(msg *events.Message) {
+	switch {
+	case msg.Status == Kill:
+		c.Cache.SetTrigger(msg.ID, c.describeKill(msg))
+	case msg.Status == OOM:
+		c.Cache.SetTrigger(msg.ID, "out of memory killer")
+	case strings.HasPrefix(msg.Status, ExecStartPrefix):
+		c.Cache.SetExec(msg.ID, strings.TrimPrefix(msg.Status, ExecStartPrefix))
+	case strings.HasPrefix(msg.Status, HealthStatusPrefix):
+		c.Cache.SetUnhealthy(msg.ID, eventType(msg) == Unhealthy)
+	}
+}
+
+// describeKill tells who likely sent the signal of a kill event. docker stop
+// sends SIGTERM, and SIGKILL once its timeout passes; docker kill sends
+// SIGKILL unless told otherwise.
+func (c *Config) describeKill(msg *events.Message) string {
+	signal := msg.Actor.Attributes["signal"]
+	if name, ok := signalNames[signal]; ok {
+		signal = name
+	} else if signal != "" {
+		signal = "signal " + signal
+	}
+
+	by := "docker kill"
+	switch {
+	case msg.Actor.Attributes[swarmTaskLabel] != "":
+		by = "swarm orchestrator"
+	case signal == "SIGTERM":
+		by = "docker stop"
+	case signal == "SIGKILL":
+		if prev := c.Cache.Trigger(msg.ID, triggerWindow); strings.HasPrefix(prev, "docker stop") {
+			by = "docker stop timeout"
+		}
+	}
+	if signal != "" {
+		by = fmt.Sprintf("%s (%s)", by, signal)
+	}
+	if c.Cache.Unhealthy(msg.ID) {
+		by += " while unhealthy"
+	}
+	return by
+}
+
+// addTrigger shows what triggered a die event. Dies without a known cause,
+// e.g. crashes, have no field.
+func (c *Config) addTrigger(ctx context.Context, cli *client.Client, m *Message, e *Event) {
+	if e.Type != Die {
+		return
+	}
+	e.TriggeredBy = c.Cache.Trigger(e.ID, triggerWindow)
+	if e.TriggeredBy == "" {
+		if cmd := c.Cache.Exec(e.ID, execWindow); cmd != "" && !isHealthcheck(ctx, cli, e.ID, cmd) {
+			e.TriggeredBy = fmt.Sprintf("docker exec (%s)", cmd)
+		}
+	}
+	if e.TriggeredBy == "" {
+		return
+	}
+	m.Attachments[0].Fields = append(m.Attachments[0].Fields, Field{
+		Title: TriggeredByField,
+		Value: e.TriggeredBy,
+		Short: true,
+	})
+}
+
+// isHealthcheck reports whether cmd is the healthcheck of id, which docker
+// runs as an exec too. It is true when id can't be inspected, so the die
+// isn't blamed on an exec which may have been a healthcheck.
+func isHealthcheck(ctx context.Context, cli *client.Client, id, cmd string) bool {
+	info, err := cli.ContainerInspect(ctx, id)
+	if err != nil {
+		log.Printf("failed to inspect %s for its healthcheck: %v", id, err)
+		return true
+	}
+	return info.Config != nil && healthcheckCommand(info.Config) == cmd
+}
+
+// healthcheckCommand is the command of the healthcheck of config as it
+// appears in exec_start events, or "" without a healthcheck
+func healthcheckCommand(config *container.Config) string {
+	if config.Healthcheck == nil || len(config.Healthcheck.Test) < 2 {
+		return ""
+	}
+	test := config.Healthcheck.Test
+	switch test[0] {
+	case "CMD":
+		return strings.Join(test[1:], " ")
+	case "CMD-SHELL":
+		shell := []string(config.Shell)
+		if len(shell) == 0 {
+			shell = []string{"/bin/sh", "-c"}
+		}
+		return strings.Join(append(shell, test[1]), " ")
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/strslice"
+)
+
+func TestRecordTrigger(t *testing.T) {
+	kill := func(signal string, labels map[string]string) events.Message {
+		attributes := map[string]string{"signal": signal}
+		for k, v := range labels {
+			attributes[k] = v
+		}
+		return events.Message{ID: "c1", Status: Kill, Actor: events.Actor{Attributes: attributes}}
+	}
+	swarm := map[string]string{swarmTaskLabel: "t1"}
+	tests := []struct {
+		name string
+		msgs []events.Message
+		want string
+	}{
+		{name: "none", want: ""},
+		{name: "stop", msgs: []events.Message{kill("15", nil)}, want: "docker stop (SIGTERM)"},
+		{name: "kill", msgs: []events.Message{kill("9", nil)}, want: "docker kill (SIGKILL)"},
+		{
+			name: "stop timeout",
+			msgs: []events.Message{kill("15", nil), kill("9", nil)},
+			want: "docker stop timeout (SIGKILL)",
+		},
+		{name: "other signal", msgs: []events.Message{kill("1", nil)}, want: "docker kill (SIGHUP)"},
+		{name: "unknown signal", msgs: []events.Message{kill("10", nil)}, want: "docker kill (signal 10)"},
+		{name: "swarm", msgs: []events.Message{kill("15", swarm)}, want: "swarm orchestrator (SIGTERM)"},
+		{
+			name: "unhealthy",
+			msgs: []events.Message{{ID: "c1", Status: HealthStatusPrefix + Unhealthy}, kill("15", nil)},
+			want: "docker stop (SIGTERM) while unhealthy",
+		},
+		{
+			name: "recovered",
+			msgs: []events.Message{
+				{ID: "c1", Status: HealthStatusPrefix + Unhealthy},
+				{ID: "c1", Status: HealthStatusPrefix + Healthy},
+				kill("15", nil),
+			},
+			want: "docker stop (SIGTERM)",
+		},
+		{name: "oom", msgs: []events.Message{{ID: "c1", Status: OOM}}, want: "out of memory killer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Cache: NewContainerCache(10)}
+			for i := range tt.msgs {
+				config.recordTrigger(&tt.msgs[i])
+			}
+			if got := config.Cache.Trigger("c1", triggerWindow); got != tt.want {
+				t.Errorf("trigger = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordTriggerExec(t *testing.T) {
+	config := &Config{Cache: NewContainerCache(10)}
+	config.recordTrigger(&events.Message{ID: "c1", Status: ExecStartPrefix + "kill 1"})
+	if got := config.Cache.Trigger("c1", triggerWindow); got != "" {
+		t.Errorf("trigger = %q, want none", got)
+	}
+	if got := config.Cache.Exec("c1", execWindow); got != "kill 1" {
+		t.Errorf("exec = %q, want %q", got, "kill 1")
+	}
+	if got := config.Cache.Exec("c1", execWindow); got != "" {
+		t.Errorf("exec after read = %q, want none", got)
+	}
+}
+
+func TestHealthcheckCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		config container.Config
+		want   string
+	}{
+		{name: "none", want: ""},
+		{
+			name:   "disabled",
+			config: container.Config{Healthcheck: &container.HealthConfig{Test: []string{"NONE"}}},
+			want:   "",
+		},
+		{
+			name:   "cmd",
+			config: container.Config{Healthcheck: &container.HealthConfig{Test: []string{"CMD", "curl", "-f", "localhost"}}},
+			want:   "curl -f localhost",
+		},
+		{
+			name:   "shell",
+			config: container.Config{Healthcheck: &container.HealthConfig{Test: []string{"CMD-SHELL", "curl -f localhost || exit 1"}}},
+			want:   "/bin/sh -c curl -f localhost || exit 1",
+		},
+		{
+			name: "custom shell",
+			config: container.Config{
+				Healthcheck: &container.HealthConfig{Test: []string{"CMD-SHELL", "true"}},
+				Shell:       strslice.StrSlice{"/bin/bash", "-c"},
+			},
+			want: "/bin/bash -c true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := healthcheckCommand(&tt.config); got != tt.want {
+				t.Errorf("healthcheckCommand = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const (
+	// URLHostAllowlistEnv is key of URL_HOST_ALLOWLIST
+	URLHostAllowlistEnv = "URL_HOST_ALLOWLIST"
+)
+
+// URLTemplate is URL of a target which may be a template rendered with the
+// Event, e.g. https://hooks.example.com/{{.Labels.team}}
+type URLTemplate struct {
+	raw       string
+	tmpl      *template.Template
+	allowlist []string
+}
+
+// NewURLTemplate is constructor. Templated URLs are only posted to hosts
+// matching one of the allowlist patterns.
+func NewURLTemplate(raw string, allowlist []string) (*URLTemplate, error) {
+	u := &URLTemplate{raw: raw}
+	if !strings.Contains(raw, "{{") {
+		return u, nil
+	}
+	if len(allowlist) == 0 {
+		return nil, fmt.Errorf("%s must be set to use the URL template %q", URLHostAllowlistEnv, raw)
+	}
+	for _, p := range allowlist {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", URLHostAllowlistEnv, err)
+		}
+	}
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	u.tmpl = tmpl
+	u.allowlist = allowlist
+	return u, nil
+}
+
+// Render returns the URL for e
+func (u *URLTemplate) Render(e *Event) (string, error) {
+	if u.tmpl == nil {
+		return u.raw, nil
+	}
+	var b bytes.Buffer
+	if err := u.tmpl.Execute(&b, e); err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(b.String())
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("rendered URL has unsupported scheme %q", parsed.Scheme)
+	}
+	host := parsed.Hostname()
+	for _, p := range u.allowlist {
+		if ok, _ := path.Match(p, host); ok {
+			return parsed.String(), nil
+		}
+	}
+	return "", fmt.Errorf("rendered URL host %q is not allowed by %s", host, URLHostAllowlistEnv)
+}
+
+func (u *URLTemplate) String() string {
+	return u.raw
+}